@@ -3,11 +3,11 @@ package builder
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/rose-pine/rose-pine-bloom/color"
@@ -18,9 +18,30 @@ type Options struct {
 	Output   string
 	Prefix   string
 	Format   string
-	Plain    bool
-	Commas   bool
-	Spaces   bool
+
+	// FormatOptions adjusts how colours are written in Format.
+	color.FormatOptions
+
+	// Strict fails the build when a template references a variable that
+	// does not exist.
+	Strict bool
+
+	// FlattenAlpha composites colours with alpha onto the named palette
+	// colour, for formats that cannot express transparency.
+	FlattenAlpha string
+
+	// Variants and Accents restrict the themes generated, by variant name
+	// or id and by accent name. All are generated when empty.
+	Variants []string
+	Accents  []string
+
+	// Palettes holds custom variants, generated after the built-in ones.
+	Palettes []color.VariantMeta
+}
+
+// AllVariants returns the built-in variants followed by the custom ones.
+func (o *Options) AllVariants() []color.VariantMeta {
+	return append(slices.Clip(color.Variants), o.Palettes...)
 }
 
 type TemplateOptions struct {
@@ -29,12 +50,16 @@ type TemplateOptions struct {
 	Variant string
 	Prefix  string
 	Format  string
-	Plain   bool
-	Commas  bool
-	Spaces  bool
 
-	DetectedFormat string
-	TemplatePath   string
+	// FormatOptions is detected along with the format when Format is
+	// empty.
+	color.FormatOptions
+
+	// DetectedFormat and DetectedOptions are the format and options the
+	// template was created with, for building it back into the same theme.
+	DetectedFormat  string
+	DetectedOptions color.FormatOptions
+	TemplatePath    string
 }
 
 const (
@@ -42,8 +67,6 @@ const (
 	resetColor = "\033[0m"
 )
 
-var variantValueRe = regexp.MustCompile(`\$\((.*?)\|(.*?)\|(.*?)\)`)
-
 func BuildTemplate(cfg *TemplateOptions) error {
 	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
 		return fmt.Errorf("create output directory: %w", err)
@@ -61,28 +84,77 @@ func Build(cfg *Options) error {
 }
 
 func generateThemes(cfg *Options) error {
-	templates, err := templateFiles(cfg.Template)
+	for _, v := range cfg.Palettes {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("palette %s: %w", v.Id, err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, v := range cfg.AllVariants() {
+		if seen[v.Id] || seen[variantName(v)] {
+			return fmt.Errorf("palette %s: duplicate variant", v.Id)
+		}
+		seen[v.Id], seen[variantName(v)] = true, true
+	}
+
+	if cfg.FlattenAlpha != "" && !slices.ContainsFunc(cfg.AllVariants(), func(v color.VariantMeta) bool {
+		_, ok := v.Colors[cfg.FlattenAlpha]
+		return ok
+	}) {
+		return fmt.Errorf("unknown colour %q to flatten alpha onto", cfg.FlattenAlpha)
+	}
+
+	if _, err := cfg.SelectedVariants(); err != nil {
+		return err
+	}
+	if len(cfg.Accents) > 0 {
+		if _, err := selectAccents(cfg.Accents); err != nil {
+			return err
+		}
+	}
+
+	paths, err := templateFiles(cfg.Template)
 	if err != nil {
 		return err
 	}
 
-	for _, tp := range templates {
+	templates := make([]*template, len(paths))
+	var errs []error
+	for i, tp := range paths {
 		content, err := os.ReadFile(tp)
 		if err != nil {
 			return err
 		}
 
-		hasAccent := strings.Contains(string(content), cfg.Prefix+"accent")
+		tmpl, err := parseTemplate(tp, string(content), cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if cfg.Strict && tmpl.cfg.Prefix == "" {
+			errs = append(errs, fmt.Errorf("%s: strict mode needs a variable prefix", tp))
+		} else if cfg.Strict {
+			errs = append(errs, tmpl.checkVariables()...)
+		}
+		templates[i] = tmpl
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
 
-		for _, v := range color.Variants {
+	for i, tp := range paths {
+		tmpl := templates[i]
+		hasAccent := tmpl.usesAccent()
+
+		for _, v := range tmpl.variants {
 			if hasAccent {
-				for _, accent := range color.Accents {
-					if err := generateThemeFile(cfg, tp, content, v, accent); err != nil {
+				for _, accent := range tmpl.accents {
+					if err := generateThemeFile(tp, tmpl, v, accent); err != nil {
 						return err
 					}
 				}
 			} else {
-				if err := generateThemeFile(cfg, tp, content, v, ""); err != nil {
+				if err := generateThemeFile(tp, tmpl, v, ""); err != nil {
 					return err
 				}
 			}
@@ -92,8 +164,109 @@ func generateThemes(cfg *Options) error {
 	return nil
 }
 
-func generateThemeFile(cfg *Options, templatePath string, content []byte, variant color.VariantMeta, accent string) error {
-	result := processTemplate(string(content), cfg, variant, accent)
+// SelectedVariants returns the variants named by o.Variants, in the order of
+// AllVariants, or all of them when none are named.
+func (o *Options) SelectedVariants() ([]color.VariantMeta, error) {
+	if len(o.Variants) == 0 {
+		return o.AllVariants(), nil
+	}
+	return selectVariants(o.AllVariants(), o.Variants)
+}
+
+// selectVariants returns the entries of variants named by names, keeping
+// their order. Variants may be given by id or by short name, e.g.
+// rose-pine-moon or moon.
+func selectVariants(variants []color.VariantMeta, names []string) ([]color.VariantMeta, error) {
+	for _, name := range names {
+		if !slices.ContainsFunc(variants, func(v color.VariantMeta) bool { return v.Id == name || variantName(v) == name }) {
+			return nil, fmt.Errorf("unknown variant %q, want one of %s", name, strings.Join(variantNames(variants), ", "))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no variants selected")
+	}
+	var selected []color.VariantMeta
+	for _, v := range variants {
+		if slices.Contains(names, v.Id) || slices.Contains(names, variantName(v)) {
+			selected = append(selected, v)
+		}
+	}
+	return selected, nil
+}
+
+// selectAccents returns the accents named by names, in palette order.
+func selectAccents(names []string) ([]string, error) {
+	for _, name := range names {
+		if !slices.Contains(color.Accents, name) {
+			return nil, fmt.Errorf("unknown accent %q, want one of %s", name, strings.Join(color.Accents, ", "))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no accents selected")
+	}
+	var selected []string
+	for _, accent := range color.Accents {
+		if slices.Contains(names, accent) {
+			selected = append(selected, accent)
+		}
+	}
+	return selected, nil
+}
+
+// variantNames returns the short names of variants.
+func variantNames(variants []color.VariantMeta) []string {
+	names := make([]string, len(variants))
+	for i, v := range variants {
+		names[i] = variantName(v)
+	}
+	return names
+}
+
+// ContrastPair is a foreground and background colour that a template uses
+// together, such as text on base.
+type ContrastPair struct {
+	Foreground string
+	Background string
+
+	// Variant is the id of the variant the pair is used in, or empty for
+	// every variant.
+	Variant string
+}
+
+// ContrastPairs returns the pairs declared with the contrast key in the
+// front matter of the templates in cfg.Template, without duplicates. Each
+// pair is given for every variant the template is built for, so that the
+// variants key of its front matter is honoured.
+func ContrastPairs(cfg *Options) ([]ContrastPair, error) {
+	paths, err := templateFiles(cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []ContrastPair
+	for _, tp := range paths {
+		content, err := os.ReadFile(tp)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := parseTemplate(tp, string(content), cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range tmpl.variants {
+			for _, pair := range tmpl.contrast {
+				pair.Variant = v.Id
+				if !slices.Contains(pairs, pair) {
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	return pairs, nil
+}
+
+func generateThemeFile(templatePath string, tmpl *template, variant color.VariantMeta, accent string) error {
+	result := tmpl.render(variant, accent)
 
 	if filepath.Ext(templatePath) == ".json" {
 		var buf bytes.Buffer
@@ -103,37 +276,78 @@ func generateThemeFile(cfg *Options, templatePath string, content []byte, varian
 		result = buf.String()
 	}
 
-	outputPath := buildOutputPath(cfg, templatePath, variant, accent)
+	outputPath := buildOutputPath(&tmpl.cfg, templatePath, variant, accent)
+	if tmpl.output != "" {
+		outputPath = filepath.Join(tmpl.cfg.Output, tmpl.outputName(variant, accent, filepath.Ext(templatePath)))
+	}
 	return writeFile(outputPath, []byte(result))
 }
 
-func detectFormatOptions(content string, variant color.VariantMeta) (color.ColorFormat, bool, bool, bool) {
-	bestFmt := color.ColorFormat("hex")
-	bestPlain, bestCommas, bestSpaces := false, true, true
-	bestCount := 0
+// detectFormatOptions returns the format and options that write the most
+// colours of variant found in content, or hex when none are found.
+func detectFormatOptions(content string, variant color.VariantMeta) (color.ColorFormat, color.FormatOptions) {
+	bestFormat, best := color.FormatHex, color.DefaultFormatOptions
+	bestCount, bestLength := 0, 0
 
 	for _, f := range color.AllFormats {
-		fmt := color.ColorFormat(f)
-		for _, plain := range []bool{false, true} {
-			for _, spaces := range []bool{true, false} {
-				for _, commas := range []bool{true, false} {
-					count := 0
-					for _, c := range variant.Colors {
-						val := color.FormatColor(c, fmt, plain, commas, spaces)
-						if strings.Contains(content, val) {
-							count++
+		format := color.ColorFormat(f)
+		// Palette indices are short numbers found in almost any file.
+		if format == color.FormatXterm256 || format == color.FormatANSI16 || format == color.FormatANSI16Escape {
+			continue
+		}
+		// Higher precisions are tried first, as values at a lower precision
+		// are often prefixes of the same values at a higher one.
+		precisions := []int{0}
+		if color.UsesPrecision(format) {
+			precisions = []int{8, 7, 6, 5, 4, 3, 2, 1}
+		}
+		uppercase := []bool{false}
+		if isHexFormat(format) {
+			uppercase = []bool{false, true}
+		}
+
+		for _, precision := range precisions {
+			for _, upper := range uppercase {
+				for _, plain := range []bool{false, true} {
+					for _, spaces := range []bool{true, false} {
+						for _, commas := range []bool{true, false} {
+							opts := color.FormatOptions{
+								Plain:     plain,
+								Commas:    commas,
+								Spaces:    spaces,
+								Precision: precision,
+								Hex:       color.HexOptions{Uppercase: upper},
+							}
+							count, length := 0, 0
+							for _, c := range variant.Colors {
+								if val := color.FormatColor(c, format, opts); strings.Contains(content, val) {
+									count++
+									length += len(val)
+								}
+							}
+							// Ties go to the longest match, so that a format with
+							// brackets or a prefix wins over the same values
+							// without them, e.g. rgb-array over plain rgb and
+							// hex-0x over plain hex.
+							if count > bestCount || count == bestCount && length > bestLength {
+								bestFormat, best, bestCount, bestLength = format, opts, count, length
+							}
 						}
 					}
-					if count > bestCount {
-						bestFmt, bestPlain, bestCommas, bestSpaces = fmt, plain, commas, spaces
-						bestCount = count
-					}
 				}
 			}
 		}
 	}
 
-	return bestFmt, bestPlain, bestCommas, bestSpaces
+	return bestFormat, best
+}
+
+func isHexFormat(format color.ColorFormat) bool {
+	switch format {
+	case color.FormatHex, color.FormatHex0x, color.FormatHexARGB, color.FormatHexBGR:
+		return true
+	}
+	return false
 }
 
 func createTemplates(cfg *TemplateOptions) error {
@@ -158,17 +372,16 @@ func createTemplates(cfg *TemplateOptions) error {
 
 		content := string(raw)
 
-		formatStr, plain, commas, spaces := cfg.Format, cfg.Plain, cfg.Commas, cfg.Spaces
-		if formatStr == "" {
-			var df color.ColorFormat
-			df, plain, commas, spaces = detectFormatOptions(content, variant)
-			formatStr = string(df)
+		format, opts := color.ColorFormat(cfg.Format), cfg.FormatOptions
+		if cfg.Format == "" {
+			format, opts = detectFormatOptions(content, variant)
 		}
+		formatStr := string(format)
 
 		data := []string{}
 
 		for name, c := range variant.Colors {
-			val := color.FormatColor(c, color.ColorFormat(formatStr), plain, commas, spaces)
+			val := color.FormatColor(c, format, opts)
 			data = append(data, val, cfg.Prefix+name)
 		}
 
@@ -176,9 +389,18 @@ func createTemplates(cfg *TemplateOptions) error {
 		data = append(data, variant.Name, cfg.Prefix+"name")
 		data = append(data, variant.Description, cfg.Prefix+"description")
 
+		// Existing prefix characters are doubled so they are not read as
+		// variables. This pair comes last so that values containing the
+		// prefix, such as hex colours with a "#" prefix, are matched first.
+		escaped := content
+		if cfg.Prefix != "" {
+			data = append(data, cfg.Prefix, cfg.Prefix+cfg.Prefix)
+			escaped = strings.ReplaceAll(content, cfg.Prefix, cfg.Prefix+cfg.Prefix)
+		}
+
 		result := strings.NewReplacer(data...).Replace(content)
 
-		if content == result {
+		if result == escaped {
 			fmt.Printf("%sNo matches for format %q. Available formats:\n  %s%s\n", warnColor, formatStr, strings.Join(color.AllFormats, ", "), resetColor)
 		}
 
@@ -187,6 +409,7 @@ func createTemplates(cfg *TemplateOptions) error {
 		outputPath := filepath.Join(cfg.Output, outputFile)
 
 		cfg.DetectedFormat = formatStr
+		cfg.DetectedOptions = opts
 		cfg.TemplatePath = outputPath
 
 		if err := writeFile(outputPath, []byte(result)); err != nil {
@@ -197,70 +420,12 @@ func createTemplates(cfg *TemplateOptions) error {
 	return nil
 }
 
-func processTemplate(content string, cfg *Options, variant color.VariantMeta, accent string) string {
-	data := []string{}
-
-	data = append(data, cfg.Prefix+"id", variant.Id)
-	data = append(data, cfg.Prefix+"name", variant.Name)
-	data = append(data, cfg.Prefix+"type", variant.Appearance)
-	data = append(data, cfg.Prefix+"appearance", variant.Appearance)
-	data = append(data, cfg.Prefix+"description", variant.Description)
-
-	if accent != "" {
-		data = append(data, cfg.Prefix+"accentname", accent)
-
-		if c, ok := variant.Colors[accent]; ok {
-			accentColor := color.FormatColor(c, color.ColorFormat(cfg.Format), cfg.Plain, cfg.Commas, cfg.Spaces)
-			data = append(data, cfg.Prefix+"accent", accentColor)
-			if c.On != "" {
-				if oc, ok := variant.Colors[c.On]; ok {
-					onAccent := color.FormatColor(oc, color.ColorFormat(cfg.Format), cfg.Plain, cfg.Commas, cfg.Spaces)
-					data = append(data, cfg.Prefix+"onaccent", onAccent)
-				}
-			}
-		}
-	}
-
-	for name, c := range variant.Colors {
-		varName := cfg.Prefix + name
-
-		alphaRe := regexp.MustCompile(regexp.QuoteMeta(varName) + `/(\d+)`)
-		matches := alphaRe.FindAllStringSubmatch(content, -1)
-		seen := make(map[string]bool)
-		for _, m := range matches {
-			if seen[m[0]] {
-				continue
-			}
-			seen[m[0]] = true
-			alpha, _ := strconv.ParseFloat(m[1], 64)
-			tmp := *c
-			a := alpha / 100
-			tmp.Alpha = &a
-			data = append(data, m[0], color.FormatColor(&tmp, color.ColorFormat(cfg.Format), cfg.Plain, cfg.Commas, cfg.Spaces))
-		}
-
-		data = append(data, varName, color.FormatColor(c, color.ColorFormat(cfg.Format), cfg.Plain, cfg.Commas, cfg.Spaces))
+func processTemplate(content string, cfg *Options, variant color.VariantMeta, accent string) (string, error) {
+	tmpl, err := parseTemplate("", content, cfg)
+	if err != nil {
+		return "", err
 	}
-
-	result := strings.NewReplacer(data...).Replace(content)
-
-	result = variantValueRe.ReplaceAllStringFunc(result, func(match string) string {
-		parts := variantValueRe.FindStringSubmatch(match)
-		if len(parts) != 4 {
-			return match
-		}
-		switch variant.Id {
-		case "rose-pine":
-			return parts[1]
-		case "rose-pine-moon":
-			return parts[2]
-		case "rose-pine-dawn":
-			return parts[3]
-		}
-		return match
-	})
-
-	return result
+	return tmpl.render(variant, accent), nil
 }
 
 func templateFiles(path string) ([]string, error) {
@@ -3,8 +3,10 @@ package builder
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -64,9 +66,8 @@ var testConfig = Options{
 	Output:   "",
 	Prefix:   "$",
 	Format:   "hex",
-	Plain:    false,
-	Commas:   true,
-	Spaces:   true,
+
+	FormatOptions: color.DefaultFormatOptions,
 }
 
 var testBuildTemplateConfig = TemplateOptions{
@@ -75,9 +76,8 @@ var testBuildTemplateConfig = TemplateOptions{
 	Variant: "moon",
 	Prefix:  "$",
 	Format:  "hex",
-	Plain:   false,
-	Commas:  true,
-	Spaces:  true,
+
+	FormatOptions: color.DefaultFormatOptions,
 }
 
 // testColor provides a standard color
@@ -146,6 +146,15 @@ func TestColorFormatting(t *testing.T) {
 	}{
 		{"hex", color.FormatHex, false, true, true, "#ebbcba"},
 		{"hex plain", color.FormatHex, true, true, true, "ebbcba"},
+		{"hex-0x", color.FormatHex0x, false, true, true, "0xebbcba"},
+		{"hex-0x plain", color.FormatHex0x, true, true, true, "ebbcba"},
+		{"hex-argb", color.FormatHexARGB, false, true, true, "#ffebbcba"},
+		{"hex-argb plain", color.FormatHexARGB, true, true, true, "ffebbcba"},
+		{"hex-bgr", color.FormatHexBGR, false, true, true, "0xbabceb"},
+		{"hex-bgr plain", color.FormatHexBGR, true, true, true, "babceb"},
+
+		{"decimal", color.FormatDecimal, false, true, true, "15449274"},
+		{"decimal-bgr", color.FormatDecimalBGR, false, true, true, "12238059"},
 
 		{"hsl", color.FormatHSL, false, true, true, "hsl(2, 55%, 83%)"},
 		{"hsl no-commas", color.FormatHSL, false, false, true, "hsl(2 55% 83%)"},
@@ -177,12 +186,37 @@ func TestColorFormatting(t *testing.T) {
 		{"rgb-css", color.FormatRGBCSS, false, true, true, "rgb(235 188 186)"},
 		{"rgb-css plain", color.FormatRGBCSS, true, true, true, "235 188 186"},
 
+		{"rgb-float", color.FormatRGBFloat, false, true, true, "0.922, 0.737, 0.729"},
+		{"rgb-float no-commas", color.FormatRGBFloat, false, false, true, "0.922 0.737 0.729"},
+		{"rgb-float no-spaces", color.FormatRGBFloat, false, true, false, "0.922,0.737,0.729"},
+		{"rgb-float-array", color.FormatRGBFloatArray, false, true, true, "[0.922, 0.737, 0.729]"},
+		{"rgb-float-array plain", color.FormatRGBFloatArray, true, true, true, "0.922, 0.737, 0.729"},
+
+		{"lab", color.FormatLab, false, true, true, "lab(80.47, 17.18, 7.78)"},
+		{"lab no-commas", color.FormatLab, false, false, true, "lab(80.47 17.18 7.78)"},
+		{"lab plain", color.FormatLab, true, true, true, "80.47, 17.18, 7.78"},
+		{"lab-css", color.FormatLabCSS, false, true, true, "lab(80.47% 17.18 7.78)"},
+		{"lch", color.FormatLCH, false, true, true, "lch(80.47, 18.86, 24.36)"},
+		{"lch-css", color.FormatLCHCSS, false, true, true, "lch(80.47% 18.86 24.36)"},
+
+		{"oklab", color.FormatOKLab, false, true, true, "oklab(0.8363, 0.05075, 0.01962)"},
+		{"oklab no-spaces", color.FormatOKLab, false, true, false, "oklab(0.8363,0.05075,0.01962)"},
+		{"oklab-css", color.FormatOKLabCSS, false, true, true, "oklab(83.63% 0.05075 0.01962)"},
+		{"oklch", color.FormatOKLCH, false, true, true, "oklch(0.8363, 0.05441, 21.14)"},
+		{"oklch-css", color.FormatOKLCHCSS, false, true, true, "oklch(83.63% 0.05441 21.14)"},
+		{"oklch-css plain", color.FormatOKLCHCSS, true, true, true, "83.63% 0.05441 21.14"},
+
 		{"ansi", color.FormatAnsi, false, true, true, "235;188;186"},
+
+		{"xterm256", color.FormatXterm256, false, true, true, "181"},
+		{"xterm256-escape", color.FormatXterm256Escape, false, true, true, "38;5;181"},
+		{"ansi16", color.FormatANSI16, false, true, true, "7"},
+		{"ansi16-escape", color.FormatANSI16Escape, false, true, true, "37"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := color.FormatColor(testColor, tt.format, tt.plain, tt.commas, tt.spaces)
+			got := color.FormatColor(testColor, tt.format, color.FormatOptions{Plain: tt.plain, Commas: tt.commas, Spaces: tt.spaces})
 			if got != tt.want {
 				t.Errorf("formatColor() = %v, want %v", got, tt.want)
 			}
@@ -206,6 +240,13 @@ func TestAlphaFormatting(t *testing.T) {
 	}{
 		{"hex", color.FormatHex, false, "#ebbcba80"},
 		{"hex plain", color.FormatHex, true, "ebbcba80"},
+		{"hex-0x", color.FormatHex0x, false, "0xebbcba80"},
+		{"hex-argb", color.FormatHexARGB, false, "#80ebbcba"},
+		{"hex-argb plain", color.FormatHexARGB, true, "80ebbcba"},
+		{"hex-bgr", color.FormatHexBGR, false, "0x80babceb"},
+
+		{"decimal", color.FormatDecimal, false, "3955014272"},
+		{"decimal-bgr", color.FormatDecimalBGR, false, "2159721707"},
 
 		{"hsl", color.FormatHSL, false, "hsla(2, 55%, 83%, 0.5)"},
 		{"hsl plain", color.FormatHSL, true, "2, 55%, 83%, 0.5"},
@@ -225,12 +266,21 @@ func TestAlphaFormatting(t *testing.T) {
 		{"rgb-array", color.FormatRGBArray, false, "[235, 188, 186, 0.5]"},
 		{"rgb-array plain", color.FormatRGBArray, true, "235, 188, 186, 0.5"},
 
+		{"rgb-float", color.FormatRGBFloat, false, "0.922, 0.737, 0.729, 0.500"},
+		{"rgb-float-array", color.FormatRGBFloatArray, false, "[0.922, 0.737, 0.729, 0.500]"},
+
+		{"lab", color.FormatLab, false, "lab(80.47, 17.18, 7.78, 0.5)"},
+		{"lab-css", color.FormatLabCSS, false, "lab(80.47% 17.18 7.78 / 0.5)"},
+		{"oklch", color.FormatOKLCH, false, "oklch(0.8363, 0.05441, 21.14, 0.5)"},
+		{"oklch-css", color.FormatOKLCHCSS, false, "oklch(83.63% 0.05441 21.14 / 0.5)"},
+		{"oklch-css plain", color.FormatOKLCHCSS, true, "83.63% 0.05441 21.14 / 0.5"},
+
 		{"ansi", color.FormatAnsi, false, "235;188;186;0.5"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := color.FormatColor(c, tt.format, tt.plain, true, true)
+			got := color.FormatColor(c, tt.format, color.FormatOptions{Plain: tt.plain, Commas: true, Spaces: true})
 			if got != tt.want {
 				t.Errorf("formatColor() = %v, want %v", got, tt.want)
 			}
@@ -343,7 +393,23 @@ func BenchmarkBuild(b *testing.B) {
 		testContent += testTemplate + "\n"
 	}
 	for b.Loop() {
-		processTemplate(testContent, &testConfig, color.MainVariantMeta, "")
+		if _, err := processTemplate(testContent, &testConfig, color.MainVariantMeta, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	testContent := ""
+	for range 20 {
+		testContent += testTemplate + "\n"
+	}
+	tmpl, err := parseTemplate("", testContent, &testConfig)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		tmpl.render(color.MainVariantMeta, "")
 	}
 }
 
@@ -443,6 +509,49 @@ func TestAccents(t *testing.T) {
 	}
 }
 
+func TestFilters(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	cfg := testConfig
+	cfg.Output = tmpDir
+	cfg.Variants = []string{"rose-pine-dawn", "moon"}
+	cfg.Accents = []string{"iris", "rose"}
+
+	buildFromTemplate(t, `{"accent": "$accent"}`, &cfg)
+
+	var got []string
+	err := filepath.WalkDir(tmpDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Base(path) != "template.json" {
+			rel, _ := filepath.Rel(tmpDir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"rose-pine-dawn/rose-pine-dawn-iris.json",
+		"rose-pine-dawn/rose-pine-dawn-rose.json",
+		"rose-pine-moon/rose-pine-moon-iris.json",
+		"rose-pine-moon/rose-pine-moon-rose.json",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("generated %v, want %v", got, want)
+	}
+
+	cfg.Variants = []string{"main", "noon"}
+	if err := Build(&cfg); err == nil || !strings.Contains(err.Error(), `unknown variant "noon", want one of main, moon, dawn`) {
+		t.Errorf("error = %v, want unknown variant", err)
+	}
+
+	cfg.Variants = nil
+	cfg.Accents = []string{"red"}
+	if err := Build(&cfg); err == nil || !strings.Contains(err.Error(), `unknown accent "red", want one of love, gold, rose, pine, foam, iris`) {
+		t.Errorf("error = %v, want unknown accent", err)
+	}
+}
+
 func TestDirectories(t *testing.T) {
 	tmpDir := setupTest(t)
 
@@ -492,6 +601,8 @@ func TestDetectFormatOptions(t *testing.T) {
 		wantPlain  bool
 		wantCommas bool
 		wantSpaces bool
+		wantPrec   int
+		wantUpper  bool
 	}{
 		{
 			name:       "hex with hash",
@@ -557,6 +668,104 @@ func TestDetectFormatOptions(t *testing.T) {
 			wantCommas: true,
 			wantSpaces: true,
 		},
+		{
+			name:       "hex uppercase",
+			content:    colors("#191724", "#EB6F92"),
+			wantFormat: color.FormatHex,
+			wantCommas: true,
+			wantSpaces: true,
+			wantUpper:  true,
+		},
+		{
+			name:       "hex 0x",
+			content:    colors("0x191724", "0xeb6f92"),
+			wantFormat: color.FormatHex0x,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "hex argb",
+			content:    colors("#ff191724", "#ffeb6f92"),
+			wantFormat: color.FormatHexARGB,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "hex argb plain",
+			content:    colors("ff191724", "ffeb6f92"),
+			wantFormat: color.FormatHexARGB,
+			wantPlain:  true,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "rgb array",
+			content:    `{"base": [25, 23, 36], "love": [235, 111, 146]}`,
+			wantFormat: color.FormatRGBArray,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "decimal bgr",
+			content:    colors("2365209", "9596907"),
+			wantFormat: color.FormatDecimalBGR,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "xterm256 escape",
+			content:    `printf "\e[38;5;234m base \e[38;5;204m love"`,
+			wantFormat: color.FormatXterm256Escape,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "palette indices",
+			content:    colors("234", "204"),
+			wantFormat: color.FormatHex,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "rgb float",
+			content:    colors("vec3(0.098, 0.090, 0.141)", "vec3(0.922, 0.435, 0.573)"),
+			wantFormat: color.FormatRGBFloat,
+			wantCommas: true,
+			wantSpaces: true,
+			wantPrec:   3,
+		},
+		{
+			name:       "rgb float precision",
+			content:    colors("0.09804 0.09020 0.14118", "0.92157 0.43529 0.57255"),
+			wantFormat: color.FormatRGBFloat,
+			wantCommas: false,
+			wantSpaces: true,
+			wantPrec:   5,
+		},
+		{
+			name:       "rgb float array",
+			content:    colors("[0.10,0.09,0.14]", "[0.92,0.44,0.57]"),
+			wantFormat: color.FormatRGBFloatArray,
+			wantCommas: true,
+			wantSpaces: false,
+			wantPrec:   2,
+		},
+		{
+			name:       "oklch css",
+			content:    colors("oklch(21.34% 0.02547 291.13)", "oklch(69.77% 0.15646 4.22)"),
+			wantFormat: color.FormatOKLCHCSS,
+			wantPlain:  false,
+			wantCommas: true,
+			wantSpaces: true,
+		},
+		{
+			name:       "lab plain",
+			content:    colors("8.46, 3.84, -8.68", "63.03, 51.34, 5.18"),
+			wantFormat: color.FormatLab,
+			wantPlain:  true,
+			wantCommas: true,
+			wantSpaces: true,
+		},
 		{
 			name:       "ansi",
 			content:    colors("25;23;36", "235;111;146"),
@@ -577,7 +786,8 @@ func TestDetectFormatOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFormat, gotPlain, gotCommas, gotSpaces := detectFormatOptions(tt.content, color.MainVariantMeta)
+			gotFormat, got := detectFormatOptions(tt.content, color.MainVariantMeta)
+			gotPlain, gotCommas, gotSpaces, gotPrec := got.Plain, got.Commas, got.Spaces, got.Precision
 			if gotFormat != tt.wantFormat {
 				t.Errorf("format = %q, want %q", gotFormat, tt.wantFormat)
 			}
@@ -590,10 +800,33 @@ func TestDetectFormatOptions(t *testing.T) {
 			if gotSpaces != tt.wantSpaces {
 				t.Errorf("spaces = %v, want %v", gotSpaces, tt.wantSpaces)
 			}
+			if gotPrec != tt.wantPrec {
+				t.Errorf("precision = %v, want %v", gotPrec, tt.wantPrec)
+			}
+			if got.Hex.Uppercase != tt.wantUpper {
+				t.Errorf("uppercase = %v, want %v", got.Hex.Uppercase, tt.wantUpper)
+			}
 		})
 	}
 }
 
+func TestDetectPatternFormat(t *testing.T) {
+	p, err := color.ParsePattern("Color({rf}, {gf}, {bf})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := color.RegisterFormat("detect-swift", p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { color.UnregisterFormat("detect-swift") })
+
+	content := "let base = Color(0.10, 0.09, 0.14)\nlet love = Color(0.92, 0.44, 0.57)\n"
+	format, opts := detectFormatOptions(content, color.MainVariantMeta)
+	if format != "detect-swift" || opts.Precision != 2 {
+		t.Errorf("detected %s with precision %d, want detect-swift with precision 2", format, opts.Precision)
+	}
+}
+
 func TestCreateAutoDetect(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -721,3 +954,602 @@ func TestCreate(t *testing.T) {
 		}
 	})
 }
+
+func TestLex(t *testing.T) {
+	items := lex(`a $highlightLow/20 $(x|$love|(y)) $`, "$", 0)
+
+	want := []item{
+		{itemText, 0, "a "},
+		{itemVariable, 2, "$highlightLow"},
+		{itemAlpha, 15, "/20"},
+		{itemText, 18, " "},
+		{itemVariantOpen, 19, "$("},
+		{itemText, 21, "x"},
+		{itemVariantSep, 22, "|"},
+		{itemVariable, 23, "$love"},
+		{itemVariantSep, 28, "|"},
+		{itemText, 29, "(y)"},
+		{itemVariantClose, 32, ")"},
+		{itemText, 33, " $"},
+		{itemEOF, 35, ""},
+	}
+
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %v", len(items), len(want), items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d = %v, want %v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestProcessTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		content string
+		want    string
+	}{
+		{"longest identifier", "$", "$highlightLow $highlightMed", "#21202e #403d52"},
+		{"unknown identifier", "$", "$lvoe $highlight", "$lvoe $highlight"},
+		{"trailing text", "$", "$base_dir", "#191724_dir"},
+		{"alpha on metadata", "$", "$id/10", "rose-pine/10"},
+		{"alpha on accent", "$", "$accent/50", "#eb6f9280"},
+		{"custom prefix", "@", "@love $love", "#eb6f92 $love"},
+		{"multi-character prefix", "{{", "{{love}}", "#eb6f92}}"},
+		{"nested parentheses", "$", "$(rgb(1, 2, 3)|b|c)", "rgb(1, 2, 3)"},
+		{"too few branches", "$", "$(echo $name)", "$(echo Rosé Pine)"},
+		{"unterminated block", "$", "$(a|b", "$(a|b"},
+		{"nested blocks", "$", "$($(1|2|3)|b|c)", "1"},
+		{"escaped prefix", "$", "$$base_dir $$$base", "$base_dir $#191724"},
+		{"format override", "$", "$love:rgb-css $love/50:hsl $love", "rgb(235 111 146) hsla(343, 76%, 68%, 0.5) #eb6f92"},
+		{"format override on call", "$", "$mix($love, $base, 0):ansi", "235;111;146"},
+		{"unknown format", "$", "$love:rgb-fancy", "#eb6f92:rgb-fancy"},
+		{"format on metadata", "$", "$id:hex", "rose-pine:hex"},
+		{"escaped variant block", "$", "$$(a|b|c)", "$(a|b|c)"},
+		{"escaped custom prefix", "@", "@@love", "@love"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig
+			cfg.Prefix = tt.prefix
+			got, err := processTemplate(tt.content, &cfg, color.MainVariantMeta, "love")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("processTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	templateContent := `{
+    "love": "$lvoe",
+    "accent": "$accent",
+    "custom": "$(main|$highlightMedium|dawn)"
+}`
+
+	cfg := testConfig
+	cfg.Output = tmpDir
+	cfg.Strict = true
+
+	templatePath := filepath.Join(tmpDir, "template.json")
+	if err := os.WriteFile(templatePath, []byte(templateContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Template = templatePath
+
+	err := Build(&cfg)
+	if err == nil {
+		t.Fatal("expected error for unknown variables")
+	}
+
+	for _, want := range []string{
+		templatePath + ":2:14: unknown variable $lvoe",
+		templatePath + ":4:23: unknown variable $highlightMedium",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got:\n%v", want, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "rose-pine")); !os.IsNotExist(err) {
+		t.Error("no themes should be generated when strict checks fail")
+	}
+
+	cfg.Prefix = ""
+	if err := Build(&cfg); err == nil || !strings.Contains(err.Error(), "strict mode needs a variable prefix") {
+		t.Errorf("error = %v, want strict mode to need a prefix", err)
+	}
+}
+
+func TestCreateEscapesPrefix(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	fileContent := `base_dir=$HOME/.config
+bg="#232136"
+`
+	expected := `base_dir=$$HOME/.config
+bg="$base"
+`
+
+	filePath := filepath.Join(tmpDir, "input.sh")
+	if err := os.WriteFile(filePath, []byte(fileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testBuildTemplateConfig
+	cfg.Output = tmpDir
+	cfg.Input = filePath
+
+	if err := BuildTemplate(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "template.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Errorf("want %s\n\n got %s", expected, string(content))
+	}
+
+	got, err := processTemplate(string(content), &testConfig, color.MoonVariantMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != fileContent {
+		t.Errorf("rebuilt template = %q, want %q", got, fileContent)
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	template := `colors:
+  bg: $base
+  $if appearance == "light"
+  shadow: $muted
+  $else
+  shadow: $base/50
+  $end
+  $if accentname == "gold" && variant != "dawn"
+  warm: true
+  $elif accentname
+  warm: false
+  $end
+mode: $if variant == "moon"
+moon$end`
+
+	tests := []struct {
+		variant color.VariantMeta
+		accent  string
+		want    string
+	}{
+		{color.MainVariantMeta, "gold", "colors:\n  bg: #191724\n  shadow: #19172480\n  warm: true\nmode: "},
+		{color.MoonVariantMeta, "love", "colors:\n  bg: #232136\n  shadow: #23213680\n  warm: false\nmode: moon"},
+		{color.DawnVariantMeta, "gold", "colors:\n  bg: #faf4ed\n  shadow: #9893a5\n  warm: false\nmode: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.variant.Id+"-"+tt.accent, func(t *testing.T) {
+			got, err := processTemplate(template, &testConfig, tt.variant, tt.accent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("processTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing end", "a\n$if appearance == \"light\"\nb", `2:1: missing $end for $if`},
+		{"unknown variable", `$if apperance == "light"` + "\n$end", `1:5: unknown condition variable apperance`},
+		{"missing condition", "$if\n$end", `1:4: missing condition`},
+		{"unterminated string", `$if id == "rose` + "\n$end", `1:11: unterminated string in condition`},
+		{"else after else", "$if id\n$else\n$else\n$end", `3:1: $else after $else`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processTemplate(tt.content, &testConfig, color.MainVariantMeta, "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "palette",
+			content: `:root {
+  $for name, colour in palette
+  --$name: $colour/50;
+  $end
+}`,
+			want: ":root {\n" +
+				"  --base: #19172480;\n  --surface: #1f1d2e80;\n  --overlay: #26233a80;\n" +
+				"  --muted: #6e6a8680;\n  --subtle: #908caa80;\n  --text: #e0def480;\n" +
+				"  --love: #eb6f9280;\n  --gold: #f6c17780;\n  --rose: #ebbcba80;\n" +
+				"  --pine: #31748f80;\n  --foam: #9ccfd880;\n  --iris: #c4a7e780;\n" +
+				"  --highlightLow: #21202e80;\n  --highlightMed: #403d5280;\n  --highlightHigh: #524f6780;\n" +
+				"}",
+		},
+		{
+			name:    "accents",
+			content: "$for accent in accents\n$accent $if accent == \"rose\"\n(default)\n$end\n$end",
+			want:    "love gold rose (default)\npine foam iris ",
+		},
+		{
+			name:    "shadows metadata",
+			content: "$for name in accents\n$name\n$end$name",
+			want:    "love\ngold\nrose\npine\nfoam\niris\nRosé Pine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processTemplate(tt.content, &testConfig, color.MainVariantMeta, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("processTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopVariablesAreLocal(t *testing.T) {
+	tmpl, err := parseTemplate("", "$for accent in accents\n$accent $lvoe\n$end", &Options{Prefix: "$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.usesAccent() {
+		t.Error("loop variable named accent should not generate accent themes")
+	}
+	if errs := tmpl.checkVariables(); len(errs) != 1 {
+		t.Errorf("checkVariables() = %v, want only $lvoe", errs)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing end", "$for name in palette\n$name", `1:1: missing $end for $for`},
+		{"unknown iterable", "$for name in colours\n$end", `1:5: cannot loop over "colours"`},
+		{"invalid clause", "$for name\n$end", `1:5: invalid loop clause "name"`},
+		{"else in loop", "$for name in palette\n$else\n$end", `2:1: unexpected $else in $for`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processTemplate(tt.content, &testConfig, color.MainVariantMeta, "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    string
+	}{
+		{"mix", "hex", "$mix($love, $base, 30)", "#ac5571"},
+		{"percent", "hex", "$mix($love, $base, 30%)", "#ac5571"},
+		{"lighten", "hex", "$lighten($overlay, 5)", "#302d4a"},
+		{"blend over", "hex", "$blend-over($love/20, $base)", "#43293a"},
+		{"nested", "hex", "$darken($mix($love, $base, 30), 0)", "#ac5571"},
+		{"alpha suffix", "hex", "$mix($love, $base, 30)/50", "#ac557180"},
+		{"format", "rgb", "$mix($love, $base, 30)", "rgb(172, 85, 113)"},
+		{"accent", "hex", "$mix($accent, $onaccent, 0)", "#eb6f92"},
+		{"not a call", "hex", "$base-dark $mix", "#191724-dark $mix"},
+		{"loop colour", "hex", "$for name, c in accents\n$mix($c, $base, 100)\n$end", "#191724\n#191724\n#191724\n#191724\n#191724\n#191724\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig
+			cfg.Format = tt.format
+			got, err := processTemplate(tt.content, &cfg, color.MainVariantMeta, "love")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("processTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionsWithoutPrefix(t *testing.T) {
+	cfg := testConfig
+	cfg.Prefix = ""
+	content := "a: mix(love, base, 30);\nb: color-mix(in srgb, love 30%, base);\nc: color-mix(in srgb, red 30%, blue);\n"
+	got, err := processTemplate(content, &cfg, color.MainVariantMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a: #ac5571;\nb: color-mix(in srgb, love 30%, base);\nc: color-mix(in srgb, red 30%, blue);\n"
+	if got != want {
+		t.Errorf("processTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown colour", "$mix($lvoe, $base, 30)", `1:6: unknown colour lvoe`},
+		{"missing argument", "$mix($love, $base)", `1:18: mix takes 3 arguments`},
+		{"extra argument", "$lighten($love, 5, 6)", `1:18: lighten takes 2 arguments`},
+		{"number for colour", "$darken(5, $love)", `1:9: expected a colour`},
+		{"loop name as colour", "$for name in palette\n$lighten($name, 5)\n$end", `2:10: unknown colour name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processTemplate(tt.content, &testConfig, color.MainVariantMeta, "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenAlpha(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	templateContent := `{
+        "base": "$base",
+        "love10": "$love/10",
+        "mixed": "$mix($love/50, $base/50, 0)"
+    }`
+
+	cfg := testConfig
+	cfg.Output = tmpDir
+	cfg.Format = "ansi"
+	cfg.FlattenAlpha = "base"
+
+	buildFromTemplate(t, templateContent, &cfg)
+
+	result := readAndParseJSON(t, filepath.Join(tmpDir, "rose-pine.json"))
+	assertJSONField(t, result, "base", "25;23;36")
+	assertJSONField(t, result, "love10", "46;32;47")
+	assertJSONField(t, result, "mixed", "130;67;91")
+
+	cfg.FlattenAlpha = "surface"
+	buildFromTemplate(t, templateContent, &cfg)
+
+	result = readAndParseJSON(t, filepath.Join(tmpDir, "rose-pine-dawn.json"))
+	assertJSONField(t, result, "love10", "248;235;231")
+
+	cfg.FlattenAlpha = "background"
+	cfg.Template = filepath.Join(tmpDir, "template.json")
+	if err := Build(&cfg); err == nil {
+		t.Error("expected error for unknown colour")
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	templateDir := filepath.Join(tmpDir, "template")
+	if err := os.Mkdir(templateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"theme.json":  `{"love": "$love"}`,
+		"shader.glsl": "---bloom\nformat: rgb-float\nprecision: 4\noutput: {id}.glsl\n---\nvec3($love)\n",
+		"qt.qss":      "---bloom\nuppercase: true\nhex-prefix: \"0x\"\noutput: {id}.qss\n---\n$love $love/50\n",
+		"colors.sh": "---bloom\n" +
+			"# shell colours\n" +
+			"format: ansi\n" +
+			"prefix: \"@\"\n" +
+			"variants: [main, rose-pine-dawn]\n" +
+			"accents: love, gold\n" +
+			"output: sh/{variant}-{accent}{ext}\n" +
+			"---\n" +
+			"accent=@accent $love\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(templateDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := testConfig
+	cfg.Output = tmpDir
+	cfg.Template = templateDir
+	if err := Build(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	result := readAndParseJSON(t, filepath.Join(tmpDir, "rose-pine.json"))
+	assertJSONField(t, result, "love", "#eb6f92")
+
+	shader, err := os.ReadFile(filepath.Join(tmpDir, "rose-pine-moon.glsl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(shader), "vec3(0.9216, 0.4353, 0.5725)\n"; got != want {
+		t.Errorf("rose-pine-moon.glsl = %q, want %q", got, want)
+	}
+
+	qss, err := os.ReadFile(filepath.Join(tmpDir, "rose-pine.qss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(qss), "0xEB6F92 0xEB6F9280\n"; got != want {
+		t.Errorf("rose-pine.qss = %q, want %q", got, want)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "sh", "dawn-gold.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "accent=234;157;52 $love\n"; got != want {
+		t.Errorf("dawn-gold.sh = %q, want %q", got, want)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"dawn-gold.sh", "dawn-love.sh", "main-gold.sh", "main-love.sh"}
+	if !slices.Equal(names, want) {
+		t.Errorf("generated %v, want %v", names, want)
+	}
+}
+
+func TestFrontMatterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unterminated", "---bloom\nformat: rgb\n", `1:1: missing --- after front matter`},
+		{"unknown key", "---bloom\ncolour: rgb\n---\n", `2:1: unknown front matter key "colour"`},
+		{"unknown format", "---bloom\nformat: rbg\n---\n", `2:9: unknown format "rbg"`},
+		{"bad bool", "---bloom\nplain: yes\n---\n", `2:8: plain must be true or false`},
+		{"bad precision", "---bloom\nprecision: 0\n---\n", `2:12: precision must be a positive whole number`},
+		{"unknown variant", "---bloom\nvariants: main, noon\n---\n", `2:11: unknown variant "noon"`},
+		{"unknown accent", "---bloom\naccents: red\n---\n", `2:10: unknown accent "red"`},
+		{"unknown field", "---bloom\noutput: {name}.json\n---\n", `2:9: unknown output field {name}`},
+		{"missing accent", "---bloom\nvariants: moon\noutput: {id}.json\n---\n$accent", `3:9: output must include {accent}`},
+		{"missing variant", "---bloom\noutput: theme.json\n---\n", `2:9: output must include {id} or {variant}`},
+		{"body positions", "---bloom\nformat: rgb\n---\n$if\n$end", `4:4: missing condition`},
+		{"contrast pair", "---bloom\ncontrast: text over base\n---\n", `2:11: contrast pair "text over base" must be written as foreground on background`},
+		{"contrast colour", "---bloom\ncontrast: text on bsae\n---\n", `2:11: unknown colour "bsae" in contrast pair`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processTemplate(tt.content, &testConfig, color.MainVariantMeta, "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomPalettes(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	custom := color.VariantMeta{
+		Id:         "acme-light",
+		Name:       "Acme Light",
+		Appearance: "light",
+		Colors:     maps.Clone(color.DawnPalette),
+	}
+	custom.Colors["love"] = color.FromRGB(color.RGB{R: 255})
+	custom.Colors["love"].On = "surface"
+	custom.Colors["brand"] = color.FromRGB(color.RGB{B: 255})
+
+	cfg := testConfig
+	cfg.Output = tmpDir
+	cfg.Palettes = []color.VariantMeta{custom}
+	cfg.Variants = []string{"main", "acme-light"}
+
+	buildFromTemplate(t, `{"id": "$id", "love": "$love", "brand": "$brand", "custom": "$(main|moon|dawn)"}`, &cfg)
+
+	result := readAndParseJSON(t, filepath.Join(tmpDir, "acme-light.json"))
+	assertJSONField(t, result, "id", "acme-light")
+	assertJSONField(t, result, "love", "#ff0000")
+	assertJSONField(t, result, "brand", "#0000ff")
+	assertJSONField(t, result, "custom", "dawn")
+
+	result = readAndParseJSON(t, filepath.Join(tmpDir, "rose-pine.json"))
+	assertJSONField(t, result, "brand", "$brand")
+
+	cfg.Strict = true
+	templatePath := filepath.Join(tmpDir, "strict.json")
+	if err := os.WriteFile(templatePath, []byte(`{"brand": "$brand", "mix": "$mix($brand, $base, 10)"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Template = templatePath
+	err := Build(&cfg)
+	for _, want := range []string{
+		templatePath + ":1:12: unknown variable $brand in variant rose-pine",
+		templatePath + ":1:34: unknown variable $brand in variant rose-pine",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q, got:\n%v", want, err)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "acme-light") {
+		t.Errorf("error should not mention acme-light, got:\n%v", err)
+	}
+	cfg.Template = ""
+
+	cfg.Palettes = []color.VariantMeta{custom, custom}
+	if err := Build(&cfg); err == nil || !strings.Contains(err.Error(), "palette acme-light: duplicate variant") {
+		t.Errorf("error = %v, want duplicate variant", err)
+	}
+
+	delete(custom.Colors, "text")
+	cfg.Palettes = []color.VariantMeta{custom}
+	if err := Build(&cfg); err == nil || !strings.Contains(err.Error(), "palette acme-light: missing colour text") {
+		t.Errorf("error = %v, want missing colour", err)
+	}
+}
+
+func TestContrastPairs(t *testing.T) {
+	tmpDir := setupTest(t)
+
+	files := map[string]string{
+		"a.json": "---bloom\ncontrast: text on base, love on surface\n---\n{}",
+		"b.json": "---bloom\ncontrast: [text on base, subtle on overlay]\nvariants: dawn\n---\n{}",
+		"c.json": "{}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := testConfig
+	cfg.Template = tmpDir
+	pairs, err := ContrastPairs(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []ContrastPair
+	for _, v := range color.Variants {
+		want = append(want, ContrastPair{"text", "base", v.Id}, ContrastPair{"love", "surface", v.Id})
+	}
+	want = append(want, ContrastPair{"subtle", "overlay", "rose-pine-dawn"})
+	if !slices.Equal(pairs, want) {
+		t.Errorf("ContrastPairs() = %v, want %v", pairs, want)
+	}
+}
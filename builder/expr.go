@@ -0,0 +1,198 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expr is a condition of an $if or $elif directive. Conditions compare
+// strings, e.g. appearance == "light" && accentname != "gold".
+type expr interface {
+	exprPos() int
+}
+
+// identExpr is a variable in a condition. local is set for loop variables.
+type identExpr struct {
+	pos   int
+	name  string
+	local bool
+}
+
+type stringExpr struct {
+	pos   int
+	value string
+}
+
+type notExpr struct {
+	pos int
+	x   expr
+}
+
+// binaryExpr is a comparison (==, !=) or a logical operator (&&, ||).
+type binaryExpr struct {
+	pos         int
+	op          string
+	left, right expr
+}
+
+func (e *identExpr) exprPos() int  { return e.pos }
+func (e *stringExpr) exprPos() int { return e.pos }
+func (e *notExpr) exprPos() int    { return e.pos }
+func (e *binaryExpr) exprPos() int { return e.pos }
+
+// exprParser is a recursive descent parser over a single condition. Offsets
+// are relative to the template so errors point at the right column.
+type exprParser struct {
+	src    string
+	base   int
+	prefix string
+	pos    int
+}
+
+type exprError struct {
+	pos int
+	msg string
+}
+
+func (e *exprError) Error() string { return e.msg }
+
+func parseExpr(src string, base int, prefix string) (expr, error) {
+	p := &exprParser{src: src, base: base, prefix: prefix}
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf("missing condition")
+	}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q in condition", p.src[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return &exprError{pos: p.base + p.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes op if it is next in the input.
+func (p *exprParser) accept(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *exprParser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		pos := p.base + p.pos
+		matched := ""
+		for _, op := range ops {
+			if p.accept(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{pos: pos, op: matched, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	return p.parseBinary(p.parseUnary, "==", "!=")
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	p.skipSpace()
+	pos := p.base + p.pos
+	if p.accept("!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{pos: pos, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	p.skipSpace()
+	start := p.pos
+
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing ) in condition")
+		}
+		return e, nil
+	}
+
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		end := p.pos + 1
+		for end < len(p.src) && p.src[end] != '"' {
+			if p.src[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(p.src) {
+			return nil, p.errorf("unterminated string in condition")
+		}
+		value, err := strconv.Unquote(p.src[p.pos : end+1])
+		if err != nil {
+			return nil, p.errorf("invalid string in condition")
+		}
+		p.pos = end + 1
+		return &stringExpr{pos: p.base + start, value: value}, nil
+	}
+
+	// Identifiers may be written with or without the variable prefix.
+	if p.prefix != "" && strings.HasPrefix(p.src[p.pos:], p.prefix) {
+		p.pos += len(p.prefix)
+	}
+	end := scanIdent(p.src, p.pos)
+	if end == p.pos {
+		if p.pos == len(p.src) {
+			return nil, p.errorf("unexpected end of condition")
+		}
+		return nil, p.errorf("unexpected %q in condition", p.src[p.pos:p.pos+1])
+	}
+	name := p.src[p.pos:end]
+	p.pos = end
+	return &identExpr{pos: p.base + start, name: name}, nil
+}
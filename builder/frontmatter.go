@@ -0,0 +1,215 @@
+package builder
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+// Front matter is an optional block at the very top of a template that
+// overrides the build options for that file:
+//
+//	---bloom
+//	format: ansi
+//	variants: main, moon
+//	output: {id}.sh
+//	---
+//
+// The block is removed from the generated themes.
+const (
+	frontMatterOpen  = "---bloom"
+	frontMatterClose = "---"
+)
+
+// outputFields are the placeholders allowed in an output pattern.
+var outputFields = []string{"id", "variant", "accent", "ext"}
+
+// parseFrontMatter applies the front matter of t.source, if any, to t and
+// returns the offset at which the template body starts.
+func (t *template) parseFrontMatter() (int, error) {
+	line, next := nextLine(t.source, 0)
+	if strings.TrimSpace(line) != frontMatterOpen {
+		return 0, nil
+	}
+
+	for pos := next; pos < len(t.source); {
+		line, next := nextLine(t.source, pos)
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == frontMatterClose:
+			return next, nil
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		default:
+			if err := t.setFrontMatter(pos, line); err != nil {
+				return 0, err
+			}
+		}
+		pos = next
+	}
+	return 0, t.errorf(0, "missing %s after front matter", frontMatterClose)
+}
+
+// setFrontMatter applies a single key: value line starting at pos.
+func (t *template) setFrontMatter(pos int, line string) error {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return t.errorf(pos, "expected key: value in front matter")
+	}
+	valuePos := pos + len(key) + 1 + len(value) - len(strings.TrimLeft(value, " \t"))
+	key = strings.TrimSpace(key)
+	value = unquote(strings.TrimSpace(value))
+
+	var err error
+	switch key {
+	case "format":
+		if !slices.Contains(color.AllFormats, value) {
+			return t.errorf(valuePos, "unknown format %q", value)
+		}
+		t.cfg.Format = value
+	case "prefix":
+		t.cfg.Prefix = value
+	case "plain":
+		t.cfg.Plain, err = strconv.ParseBool(value)
+	case "commas":
+		t.cfg.Commas, err = strconv.ParseBool(value)
+	case "spaces":
+		t.cfg.Spaces, err = strconv.ParseBool(value)
+	case "uppercase":
+		t.cfg.Hex.Uppercase, err = strconv.ParseBool(value)
+	case "short-hex":
+		t.cfg.Hex.Short, err = strconv.ParseBool(value)
+	case "alpha-first":
+		t.cfg.Hex.AlphaFirst, err = strconv.ParseBool(value)
+	case "hex-prefix":
+		t.cfg.Hex.Prefix = value
+	case "precision":
+		n, convErr := strconv.Atoi(value)
+		if convErr != nil || n < 1 {
+			return t.errorf(valuePos, "precision must be a positive whole number")
+		}
+		t.cfg.Precision = n
+	case "output":
+		if err := checkOutputPattern(value); err != nil {
+			return t.errorf(valuePos, "%v", err)
+		}
+		t.output, t.outputPos = value, valuePos
+	case "variants":
+		t.variants, err = selectVariants(t.cfg.AllVariants(), splitList(value))
+	case "accents":
+		t.accents, err = selectAccents(splitList(value))
+	case "contrast":
+		t.contrast, err = parseContrastPairs(splitList(value), t.cfg.AllVariants())
+	default:
+		return t.errorf(pos, "unknown front matter key %q", key)
+	}
+
+	if _, ok := err.(*strconv.NumError); ok {
+		return t.errorf(valuePos, "%s must be true or false", key)
+	}
+	if err != nil {
+		return t.errorf(valuePos, "%v", err)
+	}
+	return nil
+}
+
+// checkOutputUnique reports an output pattern that would write several
+// themes to the same file.
+func (t *template) checkOutputUnique(hasAccent bool) error {
+	if t.output == "" {
+		return nil
+	}
+	if len(t.variants) > 1 && !strings.Contains(t.output, "{id}") && !strings.Contains(t.output, "{variant}") {
+		return t.errorf(t.outputPos, "output must include {id} or {variant} to generate several variants")
+	}
+	if hasAccent && len(t.accents) > 1 && !strings.Contains(t.output, "{accent}") {
+		return t.errorf(t.outputPos, "output must include {accent} to generate several accents")
+	}
+	return nil
+}
+
+// outputName expands the output pattern for a theme. ext is the extension
+// of the template, including the leading dot.
+func (t *template) outputName(variant color.VariantMeta, accent, ext string) string {
+	return strings.NewReplacer(
+		"{id}", variant.Id,
+		"{variant}", variantName(variant),
+		"{accent}", accent,
+		"{ext}", ext,
+	).Replace(t.output)
+}
+
+func checkOutputPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("output must not be empty")
+	}
+	for rest := pattern; ; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return nil
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return fmt.Errorf("unterminated { in output")
+		}
+		if field := rest[open+1 : open+end]; !slices.Contains(outputFields, field) {
+			return fmt.Errorf("unknown output field {%s}, want one of {%s}", field, strings.Join(outputFields, "}, {"))
+		}
+		rest = rest[open+end+1:]
+	}
+}
+
+// parseContrastPairs parses pairs written as "foreground on background",
+// e.g. text on base.
+func parseContrastPairs(items []string, variants []color.VariantMeta) ([]ContrastPair, error) {
+	var pairs []ContrastPair
+	for _, item := range items {
+		fg, bg, ok := strings.Cut(item, " on ")
+		if !ok {
+			return nil, fmt.Errorf("contrast pair %q must be written as foreground on background", item)
+		}
+		pair := ContrastPair{Foreground: strings.TrimSpace(fg), Background: strings.TrimSpace(bg)}
+		for _, name := range []string{pair.Foreground, pair.Background} {
+			if name == "accent" || name == "onaccent" || !knownColor(name, variants) {
+				return nil, fmt.Errorf("unknown colour %q in contrast pair", name)
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// splitList splits a comma-separated list, optionally enclosed in brackets.
+func splitList(s string) []string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// unquote removes matching single or double quotes around s.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// nextLine returns the line starting at pos without its line break, and the
+// offset of the following line.
+func nextLine(s string, pos int) (string, int) {
+	end := strings.IndexByte(s[pos:], '\n')
+	if end < 0 {
+		return strings.TrimSuffix(s[pos:], "\r"), len(s)
+	}
+	return strings.TrimSuffix(s[pos:pos+end], "\r"), pos + end + 1
+}
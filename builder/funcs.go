@@ -0,0 +1,206 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+// colorFunc is a function callable from templates, e.g. $mix($love, $base, 30).
+// params lists the argument kinds: c for a colour and n for a number.
+type colorFunc struct {
+	params string
+	fn     func(c []*color.Color, n []float64) *color.Color
+}
+
+var colorFuncs = map[string]colorFunc{
+	"mix": {"ccn", func(c []*color.Color, n []float64) *color.Color {
+		return color.Mix(c[0], c[1], n[0])
+	}},
+	"lighten": {"cn", func(c []*color.Color, n []float64) *color.Color {
+		return color.Lighten(c[0], n[0])
+	}},
+	"darken": {"cn", func(c []*color.Color, n []float64) *color.Color {
+		return color.Darken(c[0], n[0])
+	}},
+	"saturate": {"cn", func(c []*color.Color, n []float64) *color.Color {
+		return color.Saturate(c[0], n[0])
+	}},
+	"desaturate": {"cn", func(c []*color.Color, n []float64) *color.Color {
+		return color.Desaturate(c[0], n[0])
+	}},
+	"blend-over": {"cc", func(c []*color.Color, n []float64) *color.Color {
+		return color.BlendOver(c[0], c[1])
+	}},
+}
+
+// callExpr is a function call. Colour arguments are colourRefs or nested
+// calls.
+type callExpr struct {
+	pos    int
+	name   string
+	colors []colorArg
+	nums   []float64
+}
+
+type colorArg interface {
+	exprPos() int
+}
+
+// colorRef is a colour argument such as $love or $love/20.
+type colorRef struct {
+	pos   int
+	name  string
+	alpha string
+	local bool
+}
+
+func (e *callExpr) exprPos() int { return e.pos }
+func (e *colorRef) exprPos() int { return e.pos }
+
+// scanCall returns the end of the function call starting at from, or from if
+// there is none. Function names may contain hyphens, e.g. blend-over.
+func scanCall(s string, from int) int {
+	end := scanIdent(s, from)
+	if end == from {
+		return from
+	}
+	for end+1 < len(s) && s[end] == '-' && isLetter(s[end+1]) {
+		end = scanIdent(s, end+1)
+	}
+	if _, ok := colorFuncs[s[from:end]]; !ok || end >= len(s) || s[end] != '(' {
+		return from
+	}
+
+	depth := 0
+	for i := end; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '\n':
+			return from
+		}
+	}
+	return from
+}
+
+// parseCall parses a function call such as mix($love, $base, 30). The
+// variable prefix is optional on function names and arguments.
+func (p *exprParser) parseCall() (*callExpr, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.prefix != "" && strings.HasPrefix(p.src[p.pos:], p.prefix) {
+		p.pos += len(p.prefix)
+	}
+
+	nameStart := p.pos
+	end := scanIdent(p.src, p.pos)
+	for end+1 < len(p.src) && p.src[end] == '-' && isLetter(p.src[end+1]) {
+		end = scanIdent(p.src, end+1)
+	}
+	name := p.src[nameStart:end]
+	fn, ok := colorFuncs[name]
+	if !ok {
+		return nil, p.errorf("unknown function %q", name)
+	}
+	p.pos = end
+
+	if !p.accept("(") {
+		return nil, p.errorf("missing ( after %s", name)
+	}
+
+	call := &callExpr{pos: p.base + start, name: name}
+	for i, kind := range fn.params {
+		if i > 0 && !p.accept(",") {
+			return nil, p.errorf("%s takes %d arguments", name, len(fn.params))
+		}
+		p.skipSpace()
+		if kind == 'n' {
+			n, err := p.parseNumber()
+			if err != nil {
+				return nil, err
+			}
+			call.nums = append(call.nums, n)
+			continue
+		}
+		c, err := p.parseColorArg()
+		if err != nil {
+			return nil, err
+		}
+		call.colors = append(call.colors, c)
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == ',' {
+		return nil, p.errorf("%s takes %d arguments", name, len(fn.params))
+	}
+	if !p.accept(")") {
+		return nil, p.errorf("missing ) after arguments to %s", name)
+	}
+	return call, nil
+}
+
+func (p *exprParser) parseNumber() (float64, error) {
+	end := p.pos
+	for end < len(p.src) && (isDigit(p.src[end]) || p.src[end] == '.' || p.src[end] == '-') {
+		end++
+	}
+	n, err := strconv.ParseFloat(p.src[p.pos:end], 64)
+	if err != nil {
+		return 0, p.errorf("expected a number")
+	}
+	p.pos = end
+	if p.pos < len(p.src) && p.src[p.pos] == '%' {
+		p.pos++
+	}
+	return n, nil
+}
+
+func (p *exprParser) parseColorArg() (colorArg, error) {
+	start := p.pos
+	from := start
+	if p.prefix != "" && strings.HasPrefix(p.src[from:], p.prefix) {
+		from += len(p.prefix)
+	}
+	if scanCall(p.src, from) > from {
+		return p.parseCall()
+	}
+
+	end := scanIdent(p.src, from)
+	if end == from {
+		return nil, p.errorf("expected a colour")
+	}
+	ref := &colorRef{pos: p.base + start, name: p.src[from:end]}
+	p.pos = end
+
+	if p.pos < len(p.src) && p.src[p.pos] == '/' {
+		digits := p.pos + 1
+		for digits < len(p.src) && isDigit(p.src[digits]) {
+			digits++
+		}
+		if digits > p.pos+1 {
+			ref.alpha = p.src[p.pos+1 : digits]
+			p.pos = digits
+		}
+	}
+	return ref, nil
+}
+
+// walkCall calls fn for every colour reference in the call, including those
+// in nested calls.
+func walkCall(call *callExpr, fn func(*colorRef)) {
+	for _, arg := range call.colors {
+		switch arg := arg.(type) {
+		case *colorRef:
+			fn(arg)
+		case *callExpr:
+			walkCall(arg, fn)
+		}
+	}
+}
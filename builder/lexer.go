@@ -0,0 +1,280 @@
+package builder
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+type itemType int
+
+const (
+	itemText         itemType = iota
+	itemVariable              // prefix followed by an identifier, e.g. $love
+	itemAlpha                 // alpha suffix directly after a variable or call, e.g. /10
+	itemCall                  // function call including its arguments, e.g. $mix($love, $base, 30)
+	itemFormat                // format override after a variable or call, e.g. :rgb-css
+	itemVariantOpen           // prefix followed by "(", e.g. $(
+	itemVariantSep            // "|" inside a variant block
+	itemVariantClose          // ")" closing a variant block
+	itemEscape                // doubled prefix, e.g. $$, written as a literal prefix
+	itemIf                    // $if
+	itemElif                  // $elif
+	itemElse                  // $else
+	itemFor                   // $for
+	itemEnd                   // $end
+	itemClause                // rest of the line following $if, $elif or $for
+	itemEOF
+)
+
+// item is a lexeme of the template language. pos is the byte offset of the
+// item in the template and val is the exact source text.
+type item struct {
+	typ itemType
+	pos int
+	val string
+}
+
+// lexer splits a template into items in a single pass. Identifiers are
+// matched greedily, so $highlightLow is never read as $highlight followed by
+// "Low".
+type lexer struct {
+	input  string
+	prefix string
+	pos    int
+	start  int
+	items  []item
+
+	// depth holds the parenthesis depth of every open variant block, so that
+	// "|" and ")" only act as delimiters at the top level of a block.
+	depth []int
+
+	// blocks counts open $if and $for blocks. $elif, $else and $end are
+	// only directives inside a block.
+	blocks int
+}
+
+// lex splits input into items, starting at offset start so that item
+// positions stay relative to the whole template.
+func lex(input, prefix string, start int) []item {
+	l := &lexer{input: input, prefix: prefix, pos: start, start: start}
+	l.run()
+	return l.items
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.input) {
+		if l.prefix != "" && strings.HasPrefix(l.input[l.pos:], l.prefix) {
+			if l.lexPrefixed() {
+				continue
+			}
+		} else if l.prefix == "" && l.atWordStart() && l.lexVariable(l.pos) {
+			continue
+		}
+
+		if len(l.depth) > 0 && l.lexDelimiter() {
+			continue
+		}
+
+		l.pos++
+	}
+	l.emitText()
+	l.items = append(l.items, item{typ: itemEOF, pos: l.pos})
+}
+
+// lexPrefixed lexes the construct starting with the prefix at l.pos and
+// reports whether one was found.
+func (l *lexer) lexPrefixed() bool {
+	next := l.pos + len(l.prefix)
+	if strings.HasPrefix(l.input[next:], l.prefix) {
+		l.emitText()
+		l.emit(itemEscape, next+len(l.prefix))
+		return true
+	}
+	if next < len(l.input) && l.input[next] == '(' {
+		l.emitText()
+		l.emit(itemVariantOpen, next+1)
+		l.depth = append(l.depth, 0)
+		return true
+	}
+	if end := scanIdent(l.input, next); end > next && l.lexKeyword(l.input[next:end], end) {
+		return true
+	}
+	return l.lexVariable(next)
+}
+
+// lexKeyword lexes a block directive and reports whether word is one.
+func (l *lexer) lexKeyword(word string, end int) bool {
+	switch {
+	case word == "if":
+		l.blocks++
+		l.lexDirective(itemIf, end)
+	case word == "for":
+		l.blocks++
+		l.lexDirective(itemFor, end)
+	case word == "elif" && l.blocks > 0:
+		l.lexDirective(itemElif, end)
+	case word == "else" && l.blocks > 0:
+		l.lexDirective(itemElse, end)
+	case word == "end" && l.blocks > 0:
+		l.blocks--
+		l.lexDirective(itemEnd, end)
+	default:
+		return false
+	}
+	return true
+}
+
+// lexDirective emits a directive ending at end. A directive on a line of its
+// own is removed along with its indentation and line break, so that blocks do
+// not leave blank lines in the output. Clauses always run to the end of the
+// line.
+func (l *lexer) lexDirective(typ itemType, end int) {
+	lineStart := strings.LastIndexByte(l.input[:l.pos], '\n') + 1
+	standalone := lineStart >= l.start && isBlank(l.input[lineStart:l.pos])
+	if standalone {
+		directive := l.pos
+		l.pos = lineStart
+		l.emitText()
+		l.pos, l.start = directive, directive
+	} else {
+		l.emitText()
+	}
+	l.emit(typ, end)
+
+	eol := strings.IndexByte(l.input[l.pos:], '\n')
+	if eol < 0 {
+		eol = len(l.input)
+	} else {
+		eol += l.pos
+	}
+
+	switch {
+	case typ == itemIf || typ == itemElif || typ == itemFor:
+		l.emit(itemClause, eol)
+	case standalone && isBlank(l.input[l.pos:eol]):
+		l.pos, l.start = eol, eol
+	default:
+		return
+	}
+
+	if l.pos < len(l.input) {
+		l.pos++
+		l.start = l.pos
+	}
+}
+
+// lexVariable lexes an identifier or function call starting at from, along
+// with an optional alpha suffix.
+func (l *lexer) lexVariable(from int) bool {
+	typ := itemCall
+	end := scanCall(l.input, from)
+	if end == from {
+		typ = itemVariable
+		end = scanIdent(l.input, from)
+	}
+	if end == from {
+		return false
+	}
+
+	l.emitText()
+	l.emit(typ, end)
+
+	if l.pos < len(l.input) && l.input[l.pos] == '/' {
+		digits := l.pos + 1
+		for digits < len(l.input) && isDigit(l.input[digits]) {
+			digits++
+		}
+		if digits > l.pos+1 {
+			l.emit(itemAlpha, digits)
+		}
+	}
+
+	if l.pos < len(l.input) && l.input[l.pos] == ':' {
+		end := l.pos + 1
+		for end < len(l.input) && (isIdentChar(l.input[end]) || l.input[end] == '-') {
+			end++
+		}
+		if slices.Contains(color.AllFormats, l.input[l.pos+1:end]) {
+			l.emit(itemFormat, end)
+		}
+	}
+	return true
+}
+
+func (l *lexer) lexDelimiter() bool {
+	top := len(l.depth) - 1
+	switch l.input[l.pos] {
+	case '(':
+		l.depth[top]++
+	case ')':
+		if l.depth[top] > 0 {
+			l.depth[top]--
+			break
+		}
+		l.emitText()
+		l.emit(itemVariantClose, l.pos+1)
+		l.depth = l.depth[:top]
+		return true
+	case '|':
+		if l.depth[top] == 0 {
+			l.emitText()
+			l.emit(itemVariantSep, l.pos+1)
+			return true
+		}
+	}
+	return false
+}
+
+func (l *lexer) atWordStart() bool {
+	return l.pos == 0 || !isIdentChar(l.input[l.pos-1])
+}
+
+// emit appends an item spanning from l.pos to end and advances past it.
+func (l *lexer) emit(typ itemType, end int) {
+	l.items = append(l.items, item{typ: typ, pos: l.pos, val: l.input[l.pos:end]})
+	l.pos = end
+	l.start = end
+}
+
+func (l *lexer) emitText() {
+	if l.pos > l.start {
+		l.items = append(l.items, item{typ: itemText, pos: l.start, val: l.input[l.start:l.pos]})
+	}
+	l.start = l.pos
+}
+
+// scanIdent returns the end of the identifier starting at from, or from if
+// there is none. Identifiers start with a letter and continue with letters
+// and digits.
+func scanIdent(s string, from int) int {
+	if from >= len(s) || !isLetter(s[from]) {
+		return from
+	}
+	end := from + 1
+	for end < len(s) && isIdentChar(s[end]) {
+		end++
+	}
+	return end
+}
+
+func isIdent(s string) bool {
+	return s != "" && scanIdent(s, 0) == len(s)
+}
+
+func isBlank(s string) bool {
+	return strings.Trim(s, " \t\r") == ""
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return isLetter(c) || isDigit(c)
+}
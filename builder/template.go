@@ -0,0 +1,920 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+type node interface {
+	position() int
+}
+
+type textNode struct {
+	pos  int
+	text string
+}
+
+// variableNode is a prefixed identifier with optional alpha and format
+// suffixes. raw holds the source text, which is written as-is when the name
+// does not resolve, and suffix the source text of the suffixes. local is set
+// for loop variables.
+type variableNode struct {
+	pos    int
+	name   string
+	alpha  string
+	format string
+	raw    string
+	suffix string
+	local  bool
+}
+
+// variantNode is a $(main|moon|dawn) block.
+type variantNode struct {
+	pos      int
+	branches [][]node
+}
+
+// ifNode is an $if block. The condition of a trailing $else branch is nil.
+type ifNode struct {
+	pos      int
+	branches []ifBranch
+}
+
+type ifBranch struct {
+	cond expr
+	body []node
+}
+
+// forNode is a $for block. key is bound to each colour or accent name and
+// the optional value to its colour.
+type forNode struct {
+	pos      int
+	key      string
+	value    string
+	iterable string
+	body     []node
+}
+
+// callNode is a function call with optional alpha and format suffixes, e.g.
+// $mix($love, $base, 30)/50:rgb-css.
+type callNode struct {
+	pos    int
+	call   *callExpr
+	alpha  string
+	format string
+	raw    string
+}
+
+func (n *textNode) position() int     { return n.pos }
+func (n *callNode) position() int     { return n.pos }
+func (n *variableNode) position() int { return n.pos }
+func (n *variantNode) position() int  { return n.pos }
+func (n *ifNode) position() int       { return n.pos }
+func (n *forNode) position() int      { return n.pos }
+
+// VariableError reports a template variable that does not resolve. Variant
+// is set when the variable is a colour that only some variants define.
+type VariableError struct {
+	File    string
+	Line    int
+	Column  int
+	Name    string
+	Variant string
+}
+
+func (e *VariableError) Error() string {
+	if e.Variant != "" {
+		return fmt.Sprintf("%s:%d:%d: unknown variable %s in variant %s", e.File, e.Line, e.Column, e.Name, e.Variant)
+	}
+	return fmt.Sprintf("%s:%d:%d: unknown variable %s", e.File, e.Line, e.Column, e.Name)
+}
+
+// SyntaxError reports a malformed template construct.
+type SyntaxError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+type template struct {
+	path   string
+	source string
+	nodes  []node
+
+	// cfg holds the options for this template after applying its front
+	// matter, along with the output pattern and the themes to generate.
+	cfg       Options
+	output    string
+	outputPos int
+	variants  []color.VariantMeta
+	accents   []string
+	contrast  []ContrastPair
+}
+
+type parser struct {
+	items  []item
+	path   string
+	source string
+	prefix string
+	pos    int
+
+	// variants holds every variant the template may be rendered with, to
+	// validate colour names against.
+	variants []color.VariantMeta
+
+	// scope holds the loop variables of the enclosing $for blocks.
+	scope []scopeVar
+}
+
+type scopeVar struct {
+	name  string
+	color bool
+}
+
+// parseTemplate parses content once so that it can be rendered for every
+// variant and accent without rescanning the source. Front matter in content
+// overrides cfg for this template. path is only used in error messages.
+func parseTemplate(path, content string, cfg *Options) (*template, error) {
+	t := &template{
+		path:    path,
+		source:  content,
+		cfg:     *cfg,
+		accents: color.Accents,
+	}
+	var err error
+	if t.variants, err = cfg.SelectedVariants(); err != nil {
+		return nil, err
+	}
+	if len(cfg.Accents) > 0 {
+		if t.accents, err = selectAccents(cfg.Accents); err != nil {
+			return nil, err
+		}
+	}
+
+	start, err := t.parseFrontMatter()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := t.cfg.Prefix
+	p := &parser{items: lex(content, prefix, start), path: path, source: content, prefix: prefix, variants: t.cfg.AllVariants()}
+
+	t.nodes, err = p.parseNodes(false)
+	if err != nil {
+		return nil, err
+	}
+	if it := p.peek(); it.typ != itemEOF {
+		return nil, p.errorf(it.pos, "unexpected %s", it.val)
+	}
+
+	if err := t.checkOutputUnique(t.usesAccent()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *template) errorf(pos int, format string, args ...any) error {
+	line, col := lineCol(t.source, pos)
+	return &SyntaxError{File: t.path, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) next() item {
+	it := p.items[p.pos]
+	if it.typ != itemEOF {
+		p.pos++
+	}
+	return it
+}
+
+func (p *parser) peek() item {
+	return p.items[p.pos]
+}
+
+func (p *parser) inScope(name string) bool {
+	return slices.ContainsFunc(p.scope, func(v scopeVar) bool { return v.name == name })
+}
+
+// inScopeColor reports whether name is a loop variable bound to a colour.
+// Inner loops shadow outer ones.
+func (p *parser) inScopeColor(name string) bool {
+	for i := len(p.scope) - 1; i >= 0; i-- {
+		if p.scope[i].name == name {
+			return p.scope[i].color
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	line, col := lineCol(p.source, pos)
+	return &SyntaxError{File: p.path, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseNodes parses until EOF or a block directive, or until the end of the
+// current branch when inVariant is set.
+func (p *parser) parseNodes(inVariant bool) ([]node, error) {
+	var nodes []node
+	for {
+		it := p.peek()
+		switch it.typ {
+		case itemEOF, itemElif, itemElse, itemEnd:
+			return nodes, nil
+		case itemVariantSep, itemVariantClose:
+			if inVariant {
+				return nodes, nil
+			}
+			p.next()
+			nodes = append(nodes, &textNode{pos: it.pos, text: it.val})
+		case itemText, itemAlpha, itemFormat:
+			p.next()
+			nodes = append(nodes, &textNode{pos: it.pos, text: it.val})
+		case itemEscape:
+			p.next()
+			nodes = append(nodes, &textNode{pos: it.pos, text: p.prefix})
+		case itemVariable:
+			nodes = append(nodes, p.parseVariable())
+		case itemCall:
+			n, err := p.parseCallNode()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		case itemVariantOpen:
+			n, err := p.parseVariant()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n...)
+		case itemIf:
+			n, err := p.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		case itemFor:
+			n, err := p.parseFor()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		default:
+			return nil, p.errorf(it.pos, "unexpected %s", it.val)
+		}
+	}
+}
+
+func (p *parser) parseVariable() node {
+	it := p.next()
+	n := &variableNode{pos: it.pos, name: it.val[len(p.prefix):]}
+	n.local = p.inScope(n.name)
+	n.alpha, n.format, n.suffix = p.parseSuffixes()
+	n.raw = it.val + n.suffix
+	return n
+}
+
+// parseSuffixes consumes the optional alpha and format suffixes following a
+// variable or call, and returns them along with their source text.
+func (p *parser) parseSuffixes() (alpha, format, raw string) {
+	if it := p.peek(); it.typ == itemAlpha {
+		p.next()
+		alpha = it.val[1:]
+		raw += it.val
+	}
+	if it := p.peek(); it.typ == itemFormat {
+		p.next()
+		format = it.val[1:]
+		raw += it.val
+	}
+	return alpha, format, raw
+}
+
+func (p *parser) parseCallNode() (node, error) {
+	it := p.next()
+	ep := &exprParser{src: it.val, base: it.pos, prefix: p.prefix}
+	call, err := ep.parseCall()
+	if err == nil && ep.pos < len(ep.src) {
+		err = ep.errorf("unexpected %q after %s", ep.src[ep.pos:], call.name)
+	}
+	// Without a prefix, text such as color-mix(in srgb, red, blue) reads like
+	// a call, so calls that do not parse are written unchanged.
+	if err != nil && p.prefix == "" {
+		return &textNode{pos: it.pos, text: it.val}, nil
+	}
+	if err != nil {
+		return nil, p.exprError(err)
+	}
+
+	var unknown *colorRef
+	walkCall(call, func(ref *colorRef) {
+		ref.local = p.inScope(ref.name)
+		if unknown != nil {
+			return
+		}
+		if (ref.local && !p.inScopeColor(ref.name)) || (!ref.local && !knownColor(ref.name, p.variants)) {
+			unknown = ref
+		}
+	})
+	if unknown != nil && p.prefix == "" {
+		return &textNode{pos: it.pos, text: it.val}, nil
+	}
+	if unknown != nil {
+		return nil, p.errorf(unknown.pos, "unknown colour %s", unknown.name)
+	}
+
+	n := &callNode{pos: it.pos, call: call}
+	var suffix string
+	n.alpha, n.format, suffix = p.parseSuffixes()
+	n.raw = it.val + suffix
+	return n, nil
+}
+
+// exprError converts an error from an exprParser into a SyntaxError.
+func (p *parser) exprError(err error) error {
+	var ee *exprError
+	if errors.As(err, &ee) {
+		return p.errorf(ee.pos, "%s", ee.msg)
+	}
+	return err
+}
+
+// parseVariant parses a variant block. Blocks that are unterminated or do not
+// have exactly one branch per built-in variant are kept as literal text.
+func (p *parser) parseVariant() ([]node, error) {
+	open := p.next()
+	n := &variantNode{pos: open.pos}
+
+	for {
+		branch, err := p.parseNodes(true)
+		if err != nil {
+			return nil, err
+		}
+		n.branches = append(n.branches, branch)
+
+		switch p.peek().typ {
+		case itemVariantSep:
+			p.next()
+			continue
+		case itemVariantClose:
+			p.next()
+		default:
+			return literalVariant(open, n.branches, false), nil
+		}
+		break
+	}
+
+	if len(n.branches) != len(color.Variants) {
+		return literalVariant(open, n.branches, true), nil
+	}
+	return []node{n}, nil
+}
+
+func literalVariant(open item, branches [][]node, closed bool) []node {
+	nodes := []node{&textNode{pos: open.pos, text: open.val}}
+	for i, branch := range branches {
+		if i > 0 {
+			nodes = append(nodes, &textNode{text: "|"})
+		}
+		nodes = append(nodes, branch...)
+	}
+	if closed {
+		nodes = append(nodes, &textNode{text: ")"})
+	}
+	return nodes
+}
+
+func (p *parser) parseIf() (node, error) {
+	open := p.next()
+	n := &ifNode{pos: open.pos}
+
+	cond, err := p.parseCondition(open)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		body, err := p.parseNodes(false)
+		if err != nil {
+			return nil, err
+		}
+		n.branches = append(n.branches, ifBranch{cond: cond, body: body})
+
+		it := p.next()
+		switch it.typ {
+		case itemElif, itemElse:
+			if cond == nil {
+				return nil, p.errorf(it.pos, "%s after %selse", it.val, p.prefix)
+			}
+			cond = nil
+			if it.typ == itemElif {
+				if cond, err = p.parseCondition(it); err != nil {
+					return nil, err
+				}
+			}
+		case itemEnd:
+			return n, nil
+		default:
+			return nil, p.errorf(open.pos, "missing %send for %s", p.prefix, open.val)
+		}
+	}
+}
+
+func (p *parser) parseCondition(directive item) (expr, error) {
+	it := p.next()
+	if it.typ != itemClause {
+		return nil, p.errorf(directive.pos, "missing condition")
+	}
+
+	cond, err := parseExpr(it.val, it.pos, p.prefix)
+	if err != nil {
+		return nil, p.exprError(err)
+	}
+
+	var unknown *identExpr
+	walkExpr(cond, func(e expr) {
+		id, ok := e.(*identExpr)
+		if !ok {
+			return
+		}
+		id.local = p.inScope(id.name)
+		if unknown == nil && !id.local && !knownCondition(id.name) {
+			unknown = id
+		}
+	})
+	if unknown != nil {
+		return nil, p.errorf(unknown.pos, "unknown condition variable %s", unknown.name)
+	}
+
+	return cond, nil
+}
+
+// parseFor parses a loop of the form
+//
+//	$for name[, colour] in palette|accents
+func (p *parser) parseFor() (node, error) {
+	open := p.next()
+	clause := p.next()
+	if clause.typ != itemClause {
+		return nil, p.errorf(open.pos, "missing loop clause")
+	}
+
+	vars, iterable, ok := strings.Cut(clause.val, " in ")
+	iterable = strings.TrimSpace(iterable)
+	key, value, _ := strings.Cut(vars, ",")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || !isIdent(key) || (value != "" && !isIdent(value)) {
+		return nil, p.errorf(clause.pos, "invalid loop clause %q, want %sfor name[, colour] in palette|accents", strings.TrimSpace(clause.val), p.prefix)
+	}
+	if iterable != "palette" && iterable != "accents" {
+		return nil, p.errorf(clause.pos, "cannot loop over %q, want palette or accents", iterable)
+	}
+
+	n := &forNode{pos: open.pos, key: key, value: value, iterable: iterable}
+
+	depth := len(p.scope)
+	p.scope = append(p.scope, scopeVar{name: key})
+	if value != "" {
+		p.scope = append(p.scope, scopeVar{name: value, color: true})
+	}
+	body, err := p.parseNodes(false)
+	p.scope = p.scope[:depth]
+	if err != nil {
+		return nil, err
+	}
+	n.body = body
+
+	if it := p.next(); it.typ != itemEnd {
+		if it.typ == itemEOF {
+			return nil, p.errorf(open.pos, "missing %send for %s", p.prefix, open.val)
+		}
+		return nil, p.errorf(it.pos, "unexpected %s in %s", it.val, open.val)
+	}
+	return n, nil
+}
+
+// usesAccent reports whether the template references any accent variable,
+// in which case a theme is generated for every accent.
+func (t *template) usesAccent() bool {
+	return walk(t.nodes, func(n node) bool {
+		switch n := n.(type) {
+		case *variableNode:
+			return !n.local && (n.name == "accent" || n.name == "onaccent" || n.name == "accentname")
+		case *callNode:
+			uses := false
+			walkCall(n.call, func(ref *colorRef) {
+				if !ref.local && (ref.name == "accent" || ref.name == "onaccent") {
+					uses = true
+				}
+			})
+			return uses
+		case *ifNode:
+			uses := false
+			for _, b := range n.branches {
+				walkExpr(b.cond, func(e expr) {
+					if id, ok := e.(*identExpr); ok && !id.local && id.name == "accentname" {
+						uses = true
+					}
+				})
+			}
+			return uses
+		}
+		return false
+	})
+}
+
+// checkVariables returns an error for every variable in the template that
+// does not resolve to a palette colour, metadata key or accent variable. A
+// colour must be defined by every variant the template is rendered with, so
+// a colour missing from some of them is reported once for each.
+func (t *template) checkVariables() []error {
+	var errs []error
+	all := t.cfg.AllVariants()
+	report := func(pos int, name, raw string) {
+		line, col := lineCol(t.source, pos)
+		if !knownVariable(name, all) {
+			errs = append(errs, &VariableError{File: t.path, Line: line, Column: col, Name: raw})
+			return
+		}
+		for _, v := range t.variants {
+			if !knownVariable(name, []color.VariantMeta{v}) {
+				errs = append(errs, &VariableError{File: t.path, Line: line, Column: col, Name: raw, Variant: v.Id})
+			}
+		}
+	}
+	walk(t.nodes, func(n node) bool {
+		switch n := n.(type) {
+		case *variableNode:
+			if !n.local {
+				report(n.pos, n.name, n.raw)
+			}
+		case *callNode:
+			walkCall(n.call, func(ref *colorRef) {
+				if !ref.local {
+					report(ref.pos, ref.name, t.cfg.Prefix+ref.name)
+				}
+			})
+		}
+		return false
+	})
+	return errs
+}
+
+// lineCol converts a byte offset into a 1-based line and column.
+func lineCol(source string, pos int) (int, int) {
+	before := source[:pos]
+	line := strings.Count(before, "\n") + 1
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCountInString(before[lineStart:]) + 1
+}
+
+func knownVariable(name string, variants []color.VariantMeta) bool {
+	switch name {
+	case "id", "name", "type", "appearance", "description", "accent", "onaccent", "accentname":
+		return true
+	}
+	for _, v := range variants {
+		if _, ok := v.Colors[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func knownColor(name string, variants []color.VariantMeta) bool {
+	if name == "accent" || name == "onaccent" {
+		return true
+	}
+	for _, v := range variants {
+		if _, ok := v.Colors[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func knownCondition(name string) bool {
+	switch name {
+	case "id", "name", "type", "appearance", "description", "accentname", "variant":
+		return true
+	}
+	return false
+}
+
+// walk calls fn for every node in the tree and reports whether fn returned
+// true for any of them.
+func walk(nodes []node, fn func(node) bool) bool {
+	for _, n := range nodes {
+		if fn(n) {
+			return true
+		}
+		switch n := n.(type) {
+		case *variantNode:
+			for _, branch := range n.branches {
+				if walk(branch, fn) {
+					return true
+				}
+			}
+		case *ifNode:
+			for _, b := range n.branches {
+				if walk(b.body, fn) {
+					return true
+				}
+			}
+		case *forNode:
+			if walk(n.body, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func walkExpr(e expr, fn func(expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch e := e.(type) {
+	case *notExpr:
+		walkExpr(e.x, fn)
+	case *binaryExpr:
+		walkExpr(e.left, fn)
+		walkExpr(e.right, fn)
+	}
+}
+
+type renderer struct {
+	cfg     *Options
+	variant color.VariantMeta
+	accent  string
+	b       strings.Builder
+
+	// locals holds the loop variables in scope, innermost last.
+	locals []binding
+}
+
+// binding is a loop variable, bound to either a name or a colour.
+type binding struct {
+	name  string
+	str   string
+	color *color.Color
+}
+
+func (t *template) render(variant color.VariantMeta, accent string) string {
+	r := &renderer{cfg: &t.cfg, variant: variant, accent: accent}
+	r.renderNodes(t.nodes)
+	return r.b.String()
+}
+
+func (r *renderer) renderNodes(nodes []node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *textNode:
+			r.b.WriteString(n.text)
+		case *variableNode:
+			r.renderVariable(n)
+		case *callNode:
+			c := r.evalCall(n.call)
+			if c == nil {
+				r.b.WriteString(n.raw)
+				break
+			}
+			r.b.WriteString(r.formatColorAs(withAlpha(c, n.alpha), n.format))
+		case *variantNode:
+			r.renderNodes(n.branches[variantIndex(r.variant)])
+		case *ifNode:
+			for _, b := range n.branches {
+				if b.cond == nil || r.truth(b.cond) {
+					r.renderNodes(b.body)
+					break
+				}
+			}
+		case *forNode:
+			r.renderFor(n)
+		}
+	}
+}
+
+func (r *renderer) renderFor(n *forNode) {
+	names := color.Accents
+	if n.iterable == "palette" {
+		names = r.variant.Colors.Names()
+	}
+
+	depth := len(r.locals)
+	for _, name := range names {
+		c, ok := r.variant.Colors[name]
+		if !ok {
+			continue
+		}
+		r.locals = append(r.locals[:depth], binding{name: n.key, str: name})
+		if n.value != "" {
+			r.locals = append(r.locals, binding{name: n.value, color: c})
+		}
+		r.renderNodes(n.body)
+	}
+	r.locals = r.locals[:depth]
+}
+
+func (r *renderer) lookupLocal(name string) *binding {
+	for i := len(r.locals) - 1; i >= 0; i-- {
+		if r.locals[i].name == name {
+			return &r.locals[i]
+		}
+	}
+	return nil
+}
+
+func (r *renderer) renderVariable(n *variableNode) {
+	c := r.lookupColor(n.name)
+	if n.local {
+		c = r.lookupLocal(n.name).color
+	}
+
+	if c != nil {
+		r.b.WriteString(r.formatColorAs(withAlpha(c, n.alpha), n.format))
+		return
+	}
+
+	s, ok := r.lookupString(n.name)
+	if n.local {
+		s, ok = r.lookupLocal(n.name).str, true
+	}
+
+	if ok {
+		r.b.WriteString(s)
+		r.b.WriteString(n.suffix)
+		return
+	}
+
+	r.b.WriteString(n.raw)
+}
+
+// evalCall returns the result of call, or nil if an argument does not
+// resolve, such as $onaccent for an accent without a foreground.
+func (r *renderer) evalCall(call *callExpr) *color.Color {
+	colors := make([]*color.Color, len(call.colors))
+	for i, arg := range call.colors {
+		switch arg := arg.(type) {
+		case *colorRef:
+			c := r.lookupColor(arg.name)
+			if arg.local {
+				c = r.lookupLocal(arg.name).color
+			}
+			if c == nil {
+				return nil
+			}
+			colors[i] = withAlpha(c, arg.alpha)
+		case *callExpr:
+			if colors[i] = r.evalCall(arg); colors[i] == nil {
+				return nil
+			}
+		}
+	}
+	return colorFuncs[call.name].fn(colors, call.nums)
+}
+
+// withAlpha returns a copy of c with the alpha given as a percentage, or c
+// itself when alpha is empty.
+func withAlpha(c *color.Color, alpha string) *color.Color {
+	if alpha == "" {
+		return c
+	}
+	pct, _ := strconv.ParseFloat(alpha, 64)
+	tmp := *c
+	a := pct / 100
+	tmp.Alpha = &a
+	return &tmp
+}
+
+func (r *renderer) lookupColor(name string) *color.Color {
+	switch name {
+	case "accent":
+		if r.accent == "" {
+			return nil
+		}
+		return r.variant.Colors[r.accent]
+	case "onaccent":
+		if r.accent == "" {
+			return nil
+		}
+		if c, ok := r.variant.Colors[r.accent]; ok && c.On != "" {
+			return r.variant.Colors[c.On]
+		}
+		return nil
+	}
+	return r.variant.Colors[name]
+}
+
+func (r *renderer) lookupString(name string) (string, bool) {
+	switch name {
+	case "id":
+		return r.variant.Id, true
+	case "name":
+		return r.variant.Name, true
+	case "type", "appearance":
+		return r.variant.Appearance, true
+	case "description":
+		return r.variant.Description, true
+	case "accentname":
+		return r.accent, r.accent != ""
+	}
+	return "", false
+}
+
+// value evaluates e as a string. Identifiers that are not set, such as
+// accentname without an accent, are empty.
+func (r *renderer) value(e expr) string {
+	switch e := e.(type) {
+	case *stringExpr:
+		return e.value
+	case *identExpr:
+		if e.local {
+			b := r.lookupLocal(e.name)
+			if b.color != nil {
+				return r.formatColor(b.color)
+			}
+			return b.str
+		}
+		if e.name == "variant" {
+			return variantName(r.variant)
+		}
+		s, _ := r.lookupString(e.name)
+		return s
+	}
+	if r.truth(e) {
+		return "true"
+	}
+	return ""
+}
+
+// truth evaluates e as a boolean. Strings are true when they are not empty.
+func (r *renderer) truth(e expr) bool {
+	switch e := e.(type) {
+	case *notExpr:
+		return !r.truth(e.x)
+	case *binaryExpr:
+		switch e.op {
+		case "&&":
+			return r.truth(e.left) && r.truth(e.right)
+		case "||":
+			return r.truth(e.left) || r.truth(e.right)
+		case "==":
+			return r.value(e.left) == r.value(e.right)
+		case "!=":
+			return r.value(e.left) != r.value(e.right)
+		}
+	}
+	return r.value(e) != ""
+}
+
+func (r *renderer) formatColor(c *color.Color) string {
+	return r.formatColorAs(c, "")
+}
+
+// formatColorAs formats c in format, or in the configured format when format
+// is empty.
+func (r *renderer) formatColorAs(c *color.Color, format string) string {
+	if format == "" {
+		format = r.cfg.Format
+	}
+	if c.Alpha != nil && r.cfg.FlattenAlpha != "" {
+		if bg, ok := r.variant.Colors[r.cfg.FlattenAlpha]; ok {
+			c = color.BlendOver(c, bg)
+		}
+	}
+	return color.FormatColor(c, color.ColorFormat(format), r.cfg.FormatOptions)
+}
+
+// variantIndex returns the branch of a $(main|moon|dawn) block used for v.
+// Custom variants use the main branch when dark and the dawn branch when
+// light.
+func variantIndex(v color.VariantMeta) int {
+	for i, known := range color.Variants {
+		if known.Id == v.Id {
+			return i
+		}
+	}
+	if v.Appearance == "light" {
+		return slices.IndexFunc(color.Variants, func(known color.VariantMeta) bool { return known.Id == color.DawnVariantMeta.Id })
+	}
+	return 0
+}
+
+// variantName returns the short name of v, e.g. moon for rose-pine-moon.
+func variantName(v color.VariantMeta) string {
+	if v.Id == color.MainVariantMeta.Id {
+		return "main"
+	}
+	return strings.TrimPrefix(v.Id, color.MainVariantMeta.Id+"-")
+}
@@ -4,64 +4,121 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/rose-pine/rose-pine-bloom/builder"
 	"github.com/rose-pine/rose-pine-bloom/color"
+	"github.com/rose-pine/rose-pine-bloom/config"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	outputDir string
-	prefix    string
-	format    string
-	plain     bool
-	noCommas  bool
-	noSpaces  bool
+	outputDir    string
+	prefix       string
+	format       string
+	plain        bool
+	noCommas     bool
+	noSpaces     bool
+	precision    int
+	uppercase    bool
+	shortHex     bool
+	alphaFirst   bool
+	hexPrefix    string
+	strict       bool
+	flattenAlpha string
+	configPath   string
+	variants     []string
+	accents      []string
+	palettePath  string
 )
 
 var buildCmd = &cobra.Command{
-	Use:   "build <template>",
+	Use:   "build [template]",
 	Short: "Generate theme files from template",
-	Args:  cobra.ExactArgs(1),
+	Long: `Generate theme files from template.
+
+Without a template, the targets declared in bloom.toml, bloom.yaml or
+bloom.json in the working directory are built instead. With one, the
+formats declared in the project file are loaded when --config is given or
+--format is not a built-in format.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		palettes := loadPalette(palettePath)
+		if len(args) == 0 {
+			buildFromConfig(cmd, palettes)
+			return
+		}
+		if configPath != "" || !slices.Contains(color.AllFormats, format) {
+			registerFormats(configPath)
+		}
+
 		template := args[0]
 
 		if !slices.Contains(color.AllFormats, format) {
 			fmt.Fprintf(os.Stderr, "invalid format %q\n", format)
 			os.Exit(1)
 		}
+		if precision < 1 {
+			fmt.Fprintf(os.Stderr, "invalid precision %d\n", precision)
+			os.Exit(1)
+		}
 
 		fmt.Printf("Building themes from %s...\n", template)
 
-		err := builder.Build(&builder.Options{
-			Template: template,
-			Output:   outputDir,
-			Prefix:   prefix,
-			Format:   format,
-			Plain:    plain,
-			Commas:   !noCommas,
-			Spaces:   !noSpaces,
-		})
-		if err != nil {
+		opts := builder.Options{
+			Template:     template,
+			Output:       outputDir,
+			Prefix:       prefix,
+			Format:       format,
+			Strict:       strict,
+			FlattenAlpha: flattenAlpha,
+			Variants:     variants,
+			Accents:      accents,
+			Palettes:     palettes,
+			FormatOptions: color.FormatOptions{
+				Plain:     plain,
+				Commas:    !noCommas,
+				Spaces:    !noSpaces,
+				Precision: precision,
+				Hex: color.HexOptions{
+					Uppercase:  uppercase,
+					Short:      shortHex,
+					AlphaFirst: alphaFirst,
+					Prefix:     hexPrefix,
+				},
+			},
+		}
+		if err := builder.Build(&opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error building themes: %v\n", err)
 			os.Exit(1)
 		}
 
 		fmt.Printf("Themes generated in %s\n", outputDir)
 
-		cmdLine := "bloom build " + template
-		cmdLine += " --output " + outputDir
-		cmdLine += " --prefix " + prefix
-		cmdLine += " --format " + format
-		if plain {
-			cmdLine += " --plain"
+		line := []string{"bloom", "build", template}
+		if configPath != "" {
+			line = append(line, "--config", configPath)
+		}
+		line = append(line, "--output", outputDir, "--prefix", prefix)
+		line = append(line, formatFlags(format, opts.FormatOptions)...)
+		if strict {
+			line = append(line, "--strict")
+		}
+		if flattenAlpha != "" {
+			line = append(line, "--flatten-alpha="+flattenAlpha)
 		}
-		if noCommas {
-			cmdLine += " --no-commas"
+		if len(variants) > 0 {
+			line = append(line, "--variants", strings.Join(variants, ","))
 		}
-		if noSpaces {
-			cmdLine += " --no-spaces"
+		if len(accents) > 0 {
+			line = append(line, "--accents", strings.Join(accents, ","))
 		}
+		if palettePath != "" {
+			line = append(line, "--palette", palettePath)
+		}
+		cmdLine := commandLine(line...)
 
 		if err := updateReadme(readmeSection(cmdLine)); err != nil {
 			fmt.Fprintf(os.Stderr, "Error updating README: %v\n", err)
@@ -71,13 +128,168 @@ var buildCmd = &cobra.Command{
 	},
 }
 
+// formatFlags returns the build flags that select format and opts, leaving
+// out options at their defaults.
+func formatFlags(format string, opts color.FormatOptions) []string {
+	flags := []string{"--format", format}
+	if opts.Plain {
+		flags = append(flags, "--plain")
+	}
+	if !opts.Commas {
+		flags = append(flags, "--no-commas")
+	}
+	if !opts.Spaces {
+		flags = append(flags, "--no-spaces")
+	}
+	if opts.Precision > 0 && opts.Precision != color.DefaultPrecision {
+		flags = append(flags, "--precision", strconv.Itoa(opts.Precision))
+	}
+	if opts.Hex.Uppercase {
+		flags = append(flags, "--uppercase")
+	}
+	if opts.Hex.Short {
+		flags = append(flags, "--short-hex")
+	}
+	if opts.Hex.AlphaFirst {
+		flags = append(flags, "--alpha-first")
+	}
+	if opts.Hex.Prefix != "" {
+		flags = append(flags, "--hex-prefix", opts.Hex.Prefix)
+	}
+	return flags
+}
+
+// loadPalette returns the custom variants in the palette file at path, if
+// one is given.
+func loadPalette(path string) []color.VariantMeta {
+	if path == "" {
+		return nil
+	}
+	palettes, err := config.LoadPalette(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading palette: %v\n", err)
+		os.Exit(1)
+	}
+	return palettes
+}
+
+// registerFormats registers the formats declared in the project file at
+// path, or in the one in the working directory if path is empty and there is
+// one, for use with a single template.
+func registerFormats(path string) {
+	if path == "" {
+		found, err := config.Find(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding config: %v\n", err)
+			os.Exit(1)
+		}
+		if found == "" {
+			return
+		}
+		path = found
+	}
+
+	cfg, err := config.LoadFormats(path)
+	if err == nil {
+		err = cfg.RegisterFormats()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildFromConfig builds every target in the project file, along with the
+// custom variants given with --palette. Other build flags are rejected, since
+// the targets set those options themselves.
+func buildFromConfig(cmd *cobra.Command, palettes []color.VariantMeta) {
+	path := configPath
+	if path == "" {
+		found, err := config.Find(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding config: %v\n", err)
+			os.Exit(1)
+		}
+		if found == "" {
+			fmt.Fprintf(os.Stderr, "no template given and no %s found\n", strings.Join(config.Names, ", "))
+			os.Exit(1)
+		}
+		path = found
+	}
+
+	var ignored []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name != "config" && f.Name != "palette" {
+			ignored = append(ignored, f.Name)
+		}
+	})
+	if len(ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "flag --%s has no effect when building from %s\n", ignored[0], path)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cfg.RegisterFormats(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	custom, err := cfg.Variants()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	palettes = append(palettes, custom...)
+
+	for _, target := range cfg.Targets {
+		opts := target.Options()
+		opts.Palettes = palettes
+		fmt.Printf("Building themes from %s...\n", opts.Template)
+		if err := builder.Build(&opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error building themes: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Themes generated in %s\n", opts.Output)
+	}
+
+	line := []string{"bloom", "build"}
+	if configPath != "" {
+		line = append(line, "--config", configPath)
+	}
+	if palettePath != "" {
+		line = append(line, "--palette", palettePath)
+	}
+	if err := updateReadme(readmeSection(commandLine(line...))); err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating README: %v\n", err)
+	} else {
+		fmt.Println("Updated README.md")
+	}
+}
+
 func init() {
 	buildCmd.Flags().StringVarP(&outputDir, "output", "o", "dist", "output directory")
 	buildCmd.Flags().StringVarP(&prefix, "prefix", "p", "$", "variable prefix")
-	buildCmd.Flags().StringVarP(&format, "format", "f", "hex", "hex, hsl, hsl-css, hsl-array, rgb, rgb-css, rgb-array, ansi")
-	buildCmd.Flags().BoolVar(&plain, "plain", false, "strip wrappers (#, rgb(), hsl(), brackets) from output")
+	buildCmd.Flags().StringVarP(&format, "format", "f", "hex", strings.Join(color.AllFormats, ", "))
+	buildCmd.Flags().BoolVar(&plain, "plain", false, "strip wrappers (#, rgb(), hsl(), oklch(), brackets) from output")
 	buildCmd.Flags().BoolVar(&noCommas, "no-commas", false, "remove commas")
 	buildCmd.Flags().BoolVar(&noSpaces, "no-spaces", false, "remove spaces")
+	buildCmd.Flags().BoolVar(&uppercase, "uppercase", false, "write hex colours in uppercase")
+	buildCmd.Flags().BoolVar(&shortHex, "short-hex", false, "write hex colours as #rgb where possible")
+	buildCmd.Flags().BoolVar(&alphaFirst, "alpha-first", false, "write alpha before the colour in hex and hex-0x, as in #aarrggbb")
+	buildCmd.Flags().StringVar(&hexPrefix, "hex-prefix", "", "prefix of hex colours in place of # or 0x")
+	buildCmd.Flags().IntVar(&precision, "precision", color.DefaultPrecision, "decimals of the float formats, e.g. rgb-float")
+	buildCmd.Flags().BoolVar(&strict, "strict", false, "fail on unknown template variables")
+	buildCmd.Flags().StringVar(&flattenAlpha, "flatten-alpha", "", "composite transparent colours onto a palette colour (default base; give a colour as --flatten-alpha=<colour>)")
+	buildCmd.Flags().Lookup("flatten-alpha").NoOptDefVal = "base"
+	buildCmd.Flags().StringSliceVar(&variants, "variants", nil, "variants to generate, e.g. main,moon (default all)")
+	buildCmd.Flags().StringSliceVar(&accents, "accents", nil, "accents to generate, e.g. rose,iris (default all)")
+	buildCmd.Flags().StringVar(&palettePath, "palette", "", "file declaring custom variants to build alongside the built-in ones")
+	buildCmd.Flags().StringVarP(&configPath, "config", "c", "", "project file declaring build targets and formats (default bloom.toml, bloom.yaml or bloom.json)")
 
 	rootCmd.AddCommand(buildCmd)
 }
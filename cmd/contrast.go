@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/rose-pine/rose-pine-bloom/builder"
+	"github.com/rose-pine/rose-pine-bloom/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	minContrast      float64
+	useAPCA          bool
+	contrastPrefix   string
+	contrastVariants []string
+	contrastPalette  string
+)
+
+// minAPCA is the default --min with --apca, the Lc recommended for body
+// text.
+const minAPCA = 60
+
+var contrastCmd = &cobra.Command{
+	Use:   "contrast [template]",
+	Short: "Check the contrast of accent and template colour pairs",
+	Long: `Check the contrast of every accent against its on colour, along with the
+pairs templates declare with the contrast key in their front matter.
+
+Exits with an error when a pair is below --min.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if useAPCA && !cmd.Flags().Changed("min") {
+			minContrast = minAPCA
+		}
+
+		opts := builder.Options{Prefix: contrastPrefix, Variants: contrastVariants, Palettes: loadPalette(contrastPalette)}
+		selected, err := opts.SelectedVariants()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking contrast: %v\n", err)
+			os.Exit(1)
+		}
+
+		var pairs []builder.ContrastPair
+		if len(args) == 1 {
+			opts.Template = args[0]
+			if pairs, err = builder.ContrastPairs(&opts); err != nil {
+				fmt.Fprintf(os.Stderr, "Error checking contrast: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if failures := writeContrast(os.Stdout, selected, pairs, useAPCA, minContrast); failures > 0 {
+			fmt.Fprintf(os.Stderr, "%d pairs below the minimum contrast of %s\n", failures, formatContrast(minContrast, useAPCA))
+			os.Exit(1)
+		}
+	},
+}
+
+// writeContrast reports the contrast of the accent and on colour pairs of
+// every variant, followed by pairs, and returns the number of pairs below
+// min. Pairs naming a colour the variant lacks, or used in another variant,
+// are skipped.
+func writeContrast(w io.Writer, variants []color.VariantMeta, pairs []builder.ContrastPair, apca bool, min float64) int {
+	failures := 0
+	for i, v := range variants {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", v.Name, v.Id)
+
+		var all []builder.ContrastPair
+		for _, name := range v.Colors.Names() {
+			if on := v.Colors[name].On; on != "" {
+				all = append(all, builder.ContrastPair{Foreground: on, Background: name})
+			}
+		}
+		for _, pair := range pairs {
+			if pair.Variant == "" || pair.Variant == v.Id {
+				all = append(all, pair)
+			}
+		}
+
+		for _, pair := range all {
+			fg, bg := v.Colors[pair.Foreground], v.Colors[pair.Background]
+			if fg == nil || bg == nil {
+				continue
+			}
+
+			label := pair.Foreground + " on " + pair.Background
+			var value float64
+			var result string
+			if apca {
+				value = color.APCA(fg, bg)
+				result = fmt.Sprintf("%-9s", formatContrast(value, true))
+			} else {
+				value = color.Contrast(fg, bg)
+				result = fmt.Sprintf("%-7s  AA %s  AAA %s", formatContrast(value, false), passFail(value >= color.ContrastAA), passFail(value >= color.ContrastAAA))
+			}
+
+			below := math.Abs(value) < min
+			if below {
+				failures++
+				result += "  below minimum"
+			}
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %-28s %s", label, result), " "))
+		}
+	}
+	return failures
+}
+
+func formatContrast(value float64, apca bool) string {
+	if apca {
+		return fmt.Sprintf("Lc %.1f", value)
+	}
+	return fmt.Sprintf("%.2f:1", value)
+}
+
+func passFail(pass bool) string {
+	if pass {
+		return "pass"
+	}
+	return "fail"
+}
+
+func init() {
+	contrastCmd.Flags().Float64Var(&minContrast, "min", color.ContrastAA, "minimum WCAG contrast ratio, or APCA Lc with --apca where it defaults to 60")
+	contrastCmd.Flags().BoolVar(&useAPCA, "apca", false, "report APCA lightness contrast instead of WCAG 2 ratios")
+	contrastCmd.Flags().StringVarP(&contrastPrefix, "prefix", "p", "$", "variable prefix")
+	contrastCmd.Flags().StringSliceVar(&contrastVariants, "variants", nil, "variants to check, e.g. main,moon (default all)")
+	contrastCmd.Flags().StringVar(&contrastPalette, "palette", "", "file declaring custom variants to check alongside the built-in ones")
+
+	rootCmd.AddCommand(contrastCmd)
+}
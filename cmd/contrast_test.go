@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rose-pine/rose-pine-bloom/builder"
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+func TestWriteContrast(t *testing.T) {
+	pairs := []builder.ContrastPair{
+		{Foreground: "text", Background: "base"},
+		{Foreground: "brand", Background: "base"},
+		{Foreground: "muted", Background: "base", Variant: "rose-pine-dawn"},
+	}
+
+	var b bytes.Buffer
+	failures := writeContrast(&b, []color.VariantMeta{color.MainVariantMeta}, pairs, false, color.ContrastAA)
+	got := b.String()
+
+	for _, line := range []string{
+		"  text on love                 2.21:1   AA fail  AAA fail  below minimum\n",
+		"  surface on gold              10.06:1  AA pass  AAA pass\n",
+		"  text on base                 13.39:1  AA pass  AAA pass\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("report is missing %q:\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "brand") {
+		t.Error("report includes a pair with a colour the variant lacks")
+	}
+	if strings.Contains(got, "muted on base") {
+		t.Error("report includes a pair used in another variant")
+	}
+	if failures != 2 {
+		t.Errorf("failures = %d, want 2", failures)
+	}
+
+	b.Reset()
+	failures = writeContrast(&b, []color.VariantMeta{color.MainVariantMeta}, nil, true, 40)
+	if !strings.Contains(b.String(), "  text on pine                 Lc -61.1\n") || failures != 0 {
+		t.Errorf("apca report with %d failures:\n%s", failures, b.String())
+	}
+}
@@ -14,8 +14,10 @@ import (
 )
 
 var (
-	variant string
-	output  string
+	variant    string
+	output     string
+	initPrefix string
+	initConfig string
 )
 
 const (
@@ -33,11 +35,17 @@ var initCmd = &cobra.Command{
 		themeFile := args[0]
 		fmt.Printf("Creating template from %s...\n", themeFile)
 
+		// Formats declared in the project file are detected alongside the
+		// built-in ones.
+		if initConfig != "" {
+			registerFormats(initConfig)
+		}
+
 		opts := &builder.TemplateOptions{
 			Input:   themeFile,
 			Output:  output,
 			Variant: variant,
-			Prefix:  prefix,
+			Prefix:  initPrefix,
 		}
 		if err := builder.BuildTemplate(opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating template: %v\n", err)
@@ -47,7 +55,11 @@ var initCmd = &cobra.Command{
 		templatePath := opts.TemplatePath
 		fmt.Printf("Template created in %s\n", output)
 
-		if err := ensureReadme(templatePath, prefix); err != nil {
+		flags := formatFlags(opts.DetectedFormat, opts.DetectedOptions)
+		if initConfig != "" {
+			flags = append([]string{"--config", initConfig}, flags...)
+		}
+		if err := ensureReadme(templatePath, initPrefix, flags...); err != nil {
 			fmt.Fprintf(os.Stderr, "Error updating README: %v\n", err)
 		} else {
 			fmt.Println("Updated README.md")
@@ -94,10 +106,41 @@ func updateReadme(section string) error {
 	return os.WriteFile(fileName, []byte(contentStr), 0644)
 }
 
-func ensureReadme(templatePath, prefix string) error {
-	cmdLine := "bloom build " + templatePath
-	cmdLine += " --prefix " + prefix
-	return updateReadme(readmeSection(cmdLine))
+// ensureReadme writes the command building templatePath into the README,
+// followed by flags.
+func ensureReadme(templatePath, prefix string, flags ...string) error {
+	args := append([]string{"bloom", "build", templatePath, "--prefix", prefix}, flags...)
+	return updateReadme(readmeSection(commandLine(args...)))
+}
+
+// commandLine joins args into a shell command, quoting those that the shell
+// would otherwise split or expand.
+func commandLine(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote returns s in single quotes unless it only holds characters the
+// shell leaves alone. A $ at the end of a word, such as the default prefix,
+// is not expanded and is left unquoted.
+func shellQuote(s string) string {
+	safe := s != "" && !strings.HasPrefix(s, "#") && !strings.HasPrefix(s, "~")
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_./,:=@%+#~", r):
+		case r == '$' && i == len(s)-1:
+		default:
+			safe = false
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 func ensureLicense() (bool, error) {
@@ -188,6 +231,7 @@ func isGitRepo() bool {
 func init() {
 	initCmd.Flags().StringVarP(&variant, "variant", "v", "main", "theme variant (main, moon, dawn)")
 	initCmd.Flags().StringVarP(&output, "output", "o", ".", "template output directory")
-	initCmd.Flags().StringVarP(&prefix, "prefix", "p", "$", "variable prefix")
+	initCmd.Flags().StringVarP(&initPrefix, "prefix", "p", "$", "variable prefix")
+	initCmd.Flags().StringVarP(&initConfig, "config", "c", "", "project file declaring custom formats to detect")
 	rootCmd.AddCommand(initCmd)
 }
@@ -12,6 +12,7 @@ func TestEnsureReadme(t *testing.T) {
 		existing     string
 		templatePath string
 		prefix       string
+		flags        []string
 		wantLines    []string
 		notWantLines []string
 	}{
@@ -46,7 +47,15 @@ func TestEnsureReadme(t *testing.T) {
 			existing:     "",
 			templatePath: "template.json",
 			prefix:       "#",
-			wantLines:    []string{"bloom build template.json --prefix #"},
+			wantLines:    []string{"bloom build template.json --prefix '#'"},
+		},
+		{
+			name:         "detected format",
+			existing:     "",
+			templatePath: "template.json",
+			prefix:       "$",
+			flags:        []string{"--format", "hex", "--uppercase", "--hex-prefix", "&H"},
+			wantLines:    []string{"bloom build template.json --prefix $ --format hex --uppercase --hex-prefix '&H'"},
 		},
 	}
 
@@ -72,7 +81,7 @@ func TestEnsureReadme(t *testing.T) {
 				}
 			}
 
-			if err := ensureReadme(tt.templatePath, tt.prefix); err != nil {
+			if err := ensureReadme(tt.templatePath, tt.prefix, tt.flags...); err != nil {
 				t.Fatal(err)
 			}
 
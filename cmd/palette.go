@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rose-pine/rose-pine-bloom/builder"
+	"github.com/rose-pine/rose-pine-bloom/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat    string
+	exportOutput    string
+	previewVariants []string
+	noColor         bool
+	simulate        string
+	minDelta        float64
+)
+
+var paletteCmd = &cobra.Command{
+	Use:   "palette",
+	Short: "Preview the Rosé Pine palette",
+	Long: `Preview the Rosé Pine palette.
+
+With --simulate, colours are shown as seen with a colour vision deficiency,
+followed by the accents that become hard to tell apart.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := builder.Options{Variants: previewVariants}
+		variants, err := opts.SelectedVariants()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error previewing palette: %v\n", err)
+			os.Exit(1)
+		}
+
+		noColorEnv := os.Getenv("NO_COLOR") != ""
+		if simulate == "" {
+			writePreview(os.Stdout, variants, !noColor && !noColorEnv)
+			return
+		}
+
+		if !slices.Contains(color.Deficiencies, simulate) {
+			fmt.Fprintf(os.Stderr, "invalid deficiency %q, want one of %s\n", simulate, strings.Join(color.Deficiencies, ", "))
+			os.Exit(1)
+		}
+		deficiency := color.Deficiency(simulate)
+		simulated := make([]color.VariantMeta, len(variants))
+		for i, v := range variants {
+			simulated[i] = simulateVariant(v, deficiency)
+		}
+		writePreview(os.Stdout, simulated, !noColor && !noColorEnv)
+		fmt.Println()
+		writeConfusions(os.Stdout, variants, deficiency, minDelta)
+	},
+}
+
+var paletteExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export every variant in a machine-readable format",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !slices.Contains(color.ExportFormats, exportFormat) {
+			fmt.Fprintf(os.Stderr, "invalid format %q\n", exportFormat)
+			os.Exit(1)
+		}
+
+		if format := color.ExportFormat(exportFormat); format == color.ExportDTCG || format == color.ExportTokens {
+			if err := exportDTCG(exportOutput); err != nil {
+				fmt.Fprintf(os.Stderr, "Error exporting palette: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		var err error
+		if exportOutput == "" {
+			err = color.Export(os.Stdout, color.Variants, color.ExportFormat(exportFormat))
+		} else {
+			err = exportFile(exportOutput, color.Variants, color.ExportFormat(exportFormat))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting palette: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// exportDTCG writes a Design Tokens file per variant into dir, named after
+// the variant id, e.g. rose-pine-moon.tokens.json.
+func exportDTCG(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, v := range color.Variants {
+		path := filepath.Join(dir, v.Id+".tokens.json")
+		if err := exportFile(path, []color.VariantMeta{v}, color.ExportDTCG); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
+
+// exportFile writes variants to the file at path, reporting errors from
+// closing it as well as from writing.
+func exportFile(path string, variants []color.VariantMeta, format color.ExportFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = color.Export(f, variants, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writePreview prints every colour of variants with its hex, RGB and HSL
+// values. swatches adds a 24-bit colour block before each colour.
+func writePreview(w io.Writer, variants []color.VariantMeta, swatches bool) {
+	for i, v := range variants {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s, %s)\n", v.Name, v.Id, v.Appearance)
+		for _, name := range v.Colors.Names() {
+			c := v.Colors[name]
+			swatch := ""
+			if swatches {
+				swatch = "\033[48;2;" + color.FormatColor(c, color.FormatAnsi, color.DefaultFormatOptions) + "m    \033[0m  "
+			}
+			fmt.Fprintf(w, "  %s%-14s %-8s  %-18s  %s\n", swatch, name,
+				color.FormatColor(c, color.FormatHex, color.DefaultFormatOptions),
+				color.FormatColor(c, color.FormatRGB, color.DefaultFormatOptions),
+				color.FormatColor(c, color.FormatHSL, color.DefaultFormatOptions),
+			)
+		}
+	}
+}
+
+// simulateVariant returns a copy of v with every colour as seen with d.
+func simulateVariant(v color.VariantMeta, d color.Deficiency) color.VariantMeta {
+	simulated := v
+	simulated.Colors = color.Palette{}
+	for name, c := range v.Colors {
+		simulated.Colors[name] = color.Simulate(c, d)
+	}
+	return simulated
+}
+
+// writeConfusions lists the accent pairs of variants whose colour
+// difference falls below min when seen with d, alongside their difference
+// with typical colour vision.
+func writeConfusions(w io.Writer, variants []color.VariantMeta, d color.Deficiency, min float64) {
+	fmt.Fprintf(w, "Accents closer than ΔE %g with %s\n", min, d)
+	found := false
+	for _, v := range variants {
+		for i, a := range color.Accents {
+			for _, b := range color.Accents[i+1:] {
+				delta := color.DeltaE(color.Simulate(v.Colors[a], d), color.Simulate(v.Colors[b], d))
+				if delta >= min {
+					continue
+				}
+				found = true
+				fmt.Fprintf(w, "  %-16s %-14s ΔE %4.1f, %.1f without %s\n", v.Id, a+" and "+b, delta, color.DeltaE(v.Colors[a], v.Colors[b]), d)
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "  none")
+	}
+}
+
+func init() {
+	paletteCmd.Flags().StringSliceVar(&previewVariants, "variant", nil, "variants to show, e.g. main,dawn (default all)")
+	paletteCmd.Flags().BoolVar(&noColor, "no-color", false, "print without colour swatches")
+	paletteCmd.Flags().StringVar(&simulate, "simulate", "", "show colours as seen with "+strings.Join(color.Deficiencies, ", "))
+	paletteCmd.Flags().Float64Var(&minDelta, "min-delta", 10, "with --simulate, flag accents closer than this CIEDE2000 difference")
+
+	paletteExportCmd.Flags().StringVarP(&exportFormat, "format", "f", "json", strings.Join(color.ExportFormats, ", "))
+	paletteExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file (default stdout); for dtcg and tokens, the directory to write <id>.tokens.json files into (default .)")
+
+	paletteCmd.AddCommand(paletteExportCmd)
+	rootCmd.AddCommand(paletteCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+func TestWritePreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		swatches bool
+		want     string
+	}{
+		{"swatches", true, "  \033[48;2;235;111;146m    \033[0m  love           #eb6f92   rgb(235, 111, 146)  hsl(343, 76%, 68%)\n"},
+		{"no colour", false, "  love           #eb6f92   rgb(235, 111, 146)  hsl(343, 76%, 68%)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			writePreview(&b, []color.VariantMeta{color.MainVariantMeta}, tt.swatches)
+			got := b.String()
+
+			if !strings.HasPrefix(got, "Rosé Pine (rose-pine, dark)\n") {
+				t.Errorf("preview does not start with the variant heading:\n%s", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("preview is missing %q:\n%s", tt.want, got)
+			}
+			if lines := strings.Count(got, "\n"); lines != len(color.ColorNames)+1 {
+				t.Errorf("preview has %d lines, want %d", lines, len(color.ColorNames)+1)
+			}
+			if !tt.swatches && strings.Contains(got, "\033") {
+				t.Error("preview without colour contains escape codes")
+			}
+		})
+	}
+}
+
+func TestWriteConfusions(t *testing.T) {
+	var b bytes.Buffer
+	writeConfusions(&b, []color.VariantMeta{color.DawnVariantMeta}, color.Deuteranopia, 10)
+	want := "Accents closer than ΔE 10 with deuteranopia\n" +
+		"  rose-pine-dawn   foam and iris  ΔE  4.4, 27.9 without deuteranopia\n"
+	if got := b.String(); got != want {
+		t.Errorf("writeConfusions() =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	writeConfusions(&b, []color.VariantMeta{color.DawnVariantMeta}, color.Deuteranopia, 1)
+	if !strings.HasSuffix(b.String(), "\n  none\n") {
+		t.Errorf("writeConfusions() without matches =\n%s", b.String())
+	}
+
+	simulated := simulateVariant(color.MainVariantMeta, color.Achromatopsia)
+	if love := simulated.Colors["love"]; love.RGB.R != love.RGB.G || love.RGB.G != love.RGB.B {
+		t.Errorf("simulated love = %v, want grey", love.RGB)
+	}
+	if color.MainPalette["love"].RGB != (color.RGB{R: 235, G: 111, B: 146}) {
+		t.Error("simulateVariant modified the palette")
+	}
+}
@@ -0,0 +1,153 @@
+package color
+
+import "math"
+
+// FromRGB returns the colour with the given RGB components and the matching
+// HSL values.
+func FromRGB(rgb RGB) *Color {
+	h, s, l := rgbToHSL(float64(rgb.R)/255, float64(rgb.G)/255, float64(rgb.B)/255)
+	return &Color{
+		HSL: HSL{H: uint16(math.Round(h)) % 360, S: uint8(math.Round(s * 100)), L: uint8(math.Round(l * 100))},
+		RGB: rgb,
+	}
+}
+
+// Mix moves a towards b by weight percent, so Mix(a, b, 0) is a and
+// Mix(a, b, 100) is b. Channels are interpolated in sRGB.
+func Mix(a, b *Color, weight float64) *Color {
+	w := clamp(weight/100, 0, 1)
+	mix := func(x, y uint8) uint8 {
+		return channel(float64(x) + (float64(y)-float64(x))*w)
+	}
+
+	c := FromRGB(RGB{R: mix(a.RGB.R, b.RGB.R), G: mix(a.RGB.G, b.RGB.G), B: mix(a.RGB.B, b.RGB.B)})
+	if a.Alpha != nil || b.Alpha != nil {
+		alpha := opacity(a) + (opacity(b)-opacity(a))*w
+		c.Alpha = &alpha
+	}
+	return c
+}
+
+// Lighten increases the HSL lightness of c by amount percentage points.
+func Lighten(c *Color, amount float64) *Color {
+	return adjustHSL(c, 0, amount)
+}
+
+// Darken decreases the HSL lightness of c by amount percentage points.
+func Darken(c *Color, amount float64) *Color {
+	return adjustHSL(c, 0, -amount)
+}
+
+// Saturate increases the HSL saturation of c by amount percentage points.
+func Saturate(c *Color, amount float64) *Color {
+	return adjustHSL(c, amount, 0)
+}
+
+// Desaturate decreases the HSL saturation of c by amount percentage points.
+func Desaturate(c *Color, amount float64) *Color {
+	return adjustHSL(c, -amount, 0)
+}
+
+// BlendOver composites fg over bg using the alpha of both colours. The
+// result is opaque when bg is opaque.
+func BlendOver(fg, bg *Color) *Color {
+	af, ab := opacity(fg), opacity(bg)
+	ao := af + ab*(1-af)
+	if ao == 0 {
+		alpha := 0.0
+		c := FromRGB(RGB{})
+		c.Alpha = &alpha
+		return c
+	}
+
+	blend := func(f, b uint8) uint8 {
+		return channel((float64(f)*af + float64(b)*ab*(1-af)) / ao)
+	}
+
+	c := FromRGB(RGB{R: blend(fg.RGB.R, bg.RGB.R), G: blend(fg.RGB.G, bg.RGB.G), B: blend(fg.RGB.B, bg.RGB.B)})
+	if ao < 1 {
+		c.Alpha = &ao
+	}
+	return c
+}
+
+func adjustHSL(c *Color, ds, dl float64) *Color {
+	h, s, l := rgbToHSL(float64(c.RGB.R)/255, float64(c.RGB.G)/255, float64(c.RGB.B)/255)
+	s = clamp(s+ds/100, 0, 1)
+	l = clamp(l+dl/100, 0, 1)
+
+	r, g, b := hslToRGB(h, s, l)
+	out := FromRGB(RGB{R: channel(r * 255), G: channel(g * 255), B: channel(b * 255)})
+	out.Alpha = c.Alpha
+	return out
+}
+
+// opacity returns the alpha of c, where a missing alpha is fully opaque.
+func opacity(c *Color) float64 {
+	if c.Alpha == nil {
+		return 1
+	}
+	return *c.Alpha
+}
+
+func channel(v float64) uint8 {
+	return uint8(math.Round(clamp(v, 0, 255)))
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// rgbToHSL converts RGB components in [0, 1] to a hue in degrees and
+// saturation and lightness in [0, 1].
+func rgbToHSL(r, g, b float64) (float64, float64, float64) {
+	hi := math.Max(r, math.Max(g, b))
+	lo := math.Min(r, math.Min(g, b))
+	l := (hi + lo) / 2
+
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l
+	}
+
+	s := d / (1 - math.Abs(2*l-1))
+
+	var h float64
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
+
+// hslToRGB is the inverse of rgbToHSL.
+func hslToRGB(h, s, l float64) (float64, float64, float64) {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
@@ -3,7 +3,13 @@ package color
 type ColorFormat string
 
 const (
-	FormatHex ColorFormat = "hex"
+	FormatHex     ColorFormat = "hex"
+	FormatHex0x   ColorFormat = "hex-0x"
+	FormatHexARGB ColorFormat = "hex-argb"
+	FormatHexBGR  ColorFormat = "hex-bgr"
+
+	FormatDecimal    ColorFormat = "decimal"
+	FormatDecimalBGR ColorFormat = "decimal-bgr"
 
 	FormatHSL      ColorFormat = "hsl"
 	FormatHSLCSS   ColorFormat = "hsl-css"
@@ -13,16 +19,42 @@ const (
 	FormatRGBCSS   ColorFormat = "rgb-css"
 	FormatRGBArray ColorFormat = "rgb-array"
 
+	FormatRGBFloat      ColorFormat = "rgb-float"
+	FormatRGBFloatArray ColorFormat = "rgb-float-array"
+
+	FormatLab      ColorFormat = "lab"
+	FormatLabCSS   ColorFormat = "lab-css"
+	FormatLCH      ColorFormat = "lch"
+	FormatLCHCSS   ColorFormat = "lch-css"
+	FormatOKLab    ColorFormat = "oklab"
+	FormatOKLabCSS ColorFormat = "oklab-css"
+	FormatOKLCH    ColorFormat = "oklch"
+	FormatOKLCHCSS ColorFormat = "oklch-css"
+
 	FormatAnsi ColorFormat = "ansi"
+
+	FormatXterm256       ColorFormat = "xterm256"
+	FormatXterm256Escape ColorFormat = "xterm256-escape"
+	FormatANSI16         ColorFormat = "ansi16"
+	FormatANSI16Escape   ColorFormat = "ansi16-escape"
 )
 
-var AllFormats = []string{
-	string(FormatHex),
-	string(FormatHSL),
-	string(FormatHSLCSS),
-	string(FormatHSLArray),
-	string(FormatRGB),
-	string(FormatRGBCSS),
-	string(FormatRGBArray),
-	string(FormatAnsi),
+// AllFormats lists the names of the registered formats: the built-in ones
+// in the order below, followed by any added with RegisterFormat.
+var AllFormats []string
+
+var builtinFormats = []ColorFormat{
+	FormatHex, FormatHex0x, FormatHexARGB, FormatHexBGR,
+	FormatDecimal, FormatDecimalBGR,
+	FormatHSL, FormatHSLCSS, FormatHSLArray,
+	FormatRGB, FormatRGBCSS, FormatRGBArray,
+	FormatRGBFloat, FormatRGBFloatArray,
+	FormatLab, FormatLabCSS, FormatLCH, FormatLCHCSS,
+	FormatOKLab, FormatOKLabCSS, FormatOKLCH, FormatOKLCHCSS,
+	FormatAnsi,
+	FormatXterm256, FormatXterm256Escape, FormatANSI16, FormatANSI16Escape,
 }
+
+// DefaultPrecision is the number of decimals written by the float formats
+// when no precision is given.
+const DefaultPrecision = 3
@@ -0,0 +1,706 @@
+package color
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAdjust(t *testing.T) {
+	love := MainPalette["love"]
+	base := MainPalette["base"]
+	overlay := MainPalette["overlay"]
+
+	alpha := 0.2
+	love20 := *love
+	love20.Alpha = &alpha
+
+	tests := []struct {
+		name string
+		got  *Color
+		want string
+	}{
+		{"mix", Mix(love, base, 30), "#ac5571"},
+		{"mix none", Mix(love, base, 0), "#eb6f92"},
+		{"mix all", Mix(love, base, 100), "#191724"},
+		{"lighten", Lighten(overlay, 5), "#302d4a"},
+		{"darken", Darken(overlay, 5), "#1c192a"},
+		{"saturate", Saturate(love, 10), "#f3678e"},
+		{"desaturate", Desaturate(love, 20), "#db7f99"},
+		{"lighten clamps", Lighten(love, 100), "#ffffff"},
+		{"blend over", BlendOver(&love20, base), "#43293a"},
+		{"blend over opaque", BlendOver(love, base), "#eb6f92"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatColor(tt.got, FormatHex, DefaultFormatOptions); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjustAlpha(t *testing.T) {
+	alpha := 0.5
+	love := *MainPalette["love"]
+	love.Alpha = &alpha
+
+	if got := Lighten(&love, 0); got.Alpha == nil || *got.Alpha != 0.5 {
+		t.Errorf("Lighten should keep alpha, got %v", got.Alpha)
+	}
+	if got := Mix(&love, MainPalette["base"], 50); got.Alpha == nil || *got.Alpha != 0.75 {
+		t.Errorf("Mix should interpolate alpha, got %v", got.Alpha)
+	}
+	if got := BlendOver(&love, MainPalette["base"]); got.Alpha != nil {
+		t.Errorf("BlendOver onto an opaque colour should be opaque, got %v", *got.Alpha)
+	}
+}
+
+func TestFromRGB(t *testing.T) {
+	tests := []struct {
+		rgb  RGB
+		want HSL
+	}{
+		{RGB{255, 0, 0}, HSL{0, 100, 50}},
+		{RGB{0, 255, 0}, HSL{120, 100, 50}},
+		{RGB{0, 0, 255}, HSL{240, 100, 50}},
+		{RGB{128, 128, 128}, HSL{0, 0, 50}},
+		{RGB{235, 111, 146}, HSL{343, 76, 68}},
+	}
+
+	for _, tt := range tests {
+		if got := FromRGB(tt.rgb).HSL; got != tt.want {
+			t.Errorf("FromRGB(%v) = %v, want %v", tt.rgb, got, tt.want)
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"#eb6f92", "#eb6f92"},
+		{"EB6F92", "#eb6f92"},
+		{"#eb6f9280", "#eb6f9280"},
+	}
+	for _, tt := range tests {
+		c, err := ParseHex(tt.in)
+		if err != nil {
+			t.Errorf("ParseHex(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := FormatColor(c, FormatHex, DefaultFormatOptions); got != tt.want {
+			t.Errorf("ParseHex(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "#eb6f9", "#eb6f9g", "rgb(235, 111, 146)"} {
+		if _, err := ParseHex(in); err == nil {
+			t.Errorf("ParseHex(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, v := range Variants {
+		if err := v.Validate(); err != nil {
+			t.Errorf("%s: %v", v.Id, err)
+		}
+	}
+
+	custom := func(edit func(*VariantMeta)) VariantMeta {
+		v := DawnVariantMeta
+		v.Id = "acme"
+		v.Colors = Palette{}
+		for name, c := range DawnPalette {
+			copied := *c
+			v.Colors[name] = &copied
+		}
+		edit(&v)
+		return v
+	}
+
+	tests := []struct {
+		name string
+		v    VariantMeta
+		want string
+	}{
+		{"id", custom(func(v *VariantMeta) { v.Id = "Acme Light" }), `id "Acme Light" must only contain lowercase letters, digits and hyphens`},
+		{"appearance", custom(func(v *VariantMeta) { v.Appearance = "dim" }), `appearance "dim" must be dark or light`},
+		{"missing colour", custom(func(v *VariantMeta) { delete(v.Colors, "muted") }), "missing colour muted"},
+		{"missing on", custom(func(v *VariantMeta) { v.Colors["pine"].On = "" }), "accent pine is missing on"},
+		{"unknown on", custom(func(v *VariantMeta) { v.Colors["pine"].On = "background" }), "colour pine is on unknown colour background"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.v.Validate(); err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	export := func(format ExportFormat) string {
+		var b bytes.Buffer
+		if err := Export(&b, Variants, format); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	var want map[string]any
+	if err := json.Unmarshal([]byte(export(ExportJSON)), &want); err != nil {
+		t.Fatal(err)
+	}
+	variants := want["variants"].([]any)
+	if len(variants) != len(Variants) {
+		t.Fatalf("exported %d variants, want %d", len(variants), len(Variants))
+	}
+	love := variants[0].(map[string]any)["colors"].(map[string]any)["love"]
+	wantLove := map[string]any{"hex": "#eb6f92", "rgb": "rgb(235, 111, 146)", "hsl": "hsl(343, 76%, 68%)", "on": "text"}
+	if !reflect.DeepEqual(love, wantLove) {
+		t.Errorf("love = %v, want %v", love, wantLove)
+	}
+
+	var fromYAML map[string]any
+	if err := yaml.Unmarshal([]byte(export(ExportYAML)), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, want) {
+		t.Error("yaml export differs from json export")
+	}
+
+	var fromTOML map[string]any
+	if _, err := toml.Decode(export(ExportTOML), &fromTOML); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(fromTOML) != fmt.Sprint(want) {
+		t.Error("toml export differs from json export")
+	}
+
+	if got := export(ExportJSON); got != export(ExportJSON) {
+		t.Error("json export is not stable")
+	}
+
+	for format, line := range map[ExportFormat]string{
+		ExportCSS:  "  --rose-pine-moon-on-love: var(--rose-pine-moon-text);\n",
+		ExportSCSS: "$rose-pine-dawn-highlightLow: #f4ede8;\n",
+		ExportLess: "@rose-pine-on-gold: @rose-pine-surface;\n",
+	} {
+		if got := export(format); !strings.Contains(got, line) {
+			t.Errorf("%s export is missing %q", format, line)
+		}
+	}
+}
+
+func TestDTCG(t *testing.T) {
+	var b bytes.Buffer
+	if err := Export(&b, Variants, ExportDTCG); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseDTCG(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, Variants) {
+		t.Error("variants changed in a round trip through DTCG")
+	}
+
+	var alias bytes.Buffer
+	if err := Export(&alias, Variants, ExportTokens); err != nil {
+		t.Fatal(err)
+	}
+	if alias.String() != b.String() {
+		t.Error("tokens export differs from dtcg")
+	}
+
+	tokens := `{
+  "brand": {
+    "base": {"$type": "color", "$value": "#faf4ed"},
+    "love": {"$type": "color", "$value": {"colorSpace": "srgb", "components": [1, 0, 0], "alpha": 0.5}},
+    "accent": {"$type": "color", "$value": "{brand.love}"},
+    "on": {"love": {"$value": "{brand.base}"}}
+  }
+}`
+	variants, err := ParseDTCG([]byte(tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := variants[0]
+	if v.Id != "brand" || v.Name != "brand" || v.Appearance != "light" {
+		t.Errorf("variant = %s, %s, %s, want brand, brand, light", v.Id, v.Name, v.Appearance)
+	}
+	if got := FormatColor(v.Colors["accent"], FormatHex, DefaultFormatOptions); got != "#ff000080" {
+		t.Errorf("accent = %s, want #ff000080", got)
+	}
+	if v.Colors["love"].On != "base" || v.Colors["accent"].On != "" {
+		t.Errorf("on = %q, %q, want base and none", v.Colors["love"].On, v.Colors["accent"].On)
+	}
+
+	for _, tokens := range []string{
+		`{}`,
+		`{"brand": {"base": {"$value": "#faf4ed", "$type": "dimension"}}}`,
+		`{"brand": {"base": {"$value": "{brand.missing}"}}}`,
+		`{"brand": {"base": {"$value": {"colorSpace": "oklch", "components": [0.5, 0.1, 20]}}}}`,
+		`{"brand": {"base": {"$value": "#faf4ed"}, "on": {"base": {"$value": "{other.base}"}}}}`,
+		`{"brand": {"$description": 1, "base": {"$value": "#faf4ed"}}}`,
+		`{"brand": {"base": {"$value": "#faf4ed"}, "on": {"base": {"$value": 1}}}}`,
+	} {
+		if _, err := ParseDTCG([]byte(tokens)); err == nil {
+			t.Errorf("ParseDTCG(%s) succeeded, want error", tokens)
+		}
+	}
+}
+
+func TestContrast(t *testing.T) {
+	white := &Color{RGB: RGB{255, 255, 255}}
+	black := &Color{RGB: RGB{0, 0, 0}}
+	grey := &Color{RGB: RGB{0x88, 0x88, 0x88}}
+	half := 0.5
+	transparentBlack := &Color{RGB: RGB{0, 0, 0}, Alpha: &half}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"black on white", Contrast(black, white), 21},
+		{"white on black", Contrast(white, black), 21},
+		{"grey on white", Contrast(grey, white), 3.54},
+		{"same colour", Contrast(grey, grey), 1},
+		{"transparent", Contrast(transparentBlack, white), Contrast(BlendOver(transparentBlack, white), white)},
+		{"text on love", Contrast(MainPalette["text"], MainPalette["love"]), 2.21},
+		{"apca black on white", APCA(black, white), 106.04},
+		{"apca white on black", APCA(white, black), -107.88},
+		{"apca grey on white", APCA(grey, white), 63.06},
+		{"apca same colour", APCA(grey, grey), 0},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 0.01 {
+			t.Errorf("%s = %.3f, want %.2f", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		deficiency Deficiency
+		in, want   RGB
+	}{
+		{Protanopia, RGB{255, 0, 0}, RGB{109, 95, 0}},
+		{Deuteranopia, RGB{255, 0, 0}, RGB{163, 144, 0}},
+		{Tritanopia, RGB{0, 0, 255}, RGB{0, 107, 150}},
+		{Achromatopsia, RGB{255, 0, 0}, RGB{127, 127, 127}},
+		{Deuteranopia, RGB{255, 255, 255}, RGB{255, 255, 255}},
+		{Achromatopsia, RGB{0, 0, 0}, RGB{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got := Simulate(FromRGB(tt.in), tt.deficiency)
+		if got.RGB != tt.want {
+			t.Errorf("Simulate(%v, %s) = %v, want %v", tt.in, tt.deficiency, got.RGB, tt.want)
+		}
+		if got.HSL != FromRGB(got.RGB).HSL {
+			t.Errorf("Simulate(%v, %s) HSL = %v, does not match its RGB", tt.in, tt.deficiency, got.HSL)
+		}
+	}
+
+	love := Simulate(MainPalette["love"], Achromatopsia)
+	if love.On != "text" {
+		t.Errorf("Simulate() On = %q, want text", love.On)
+	}
+}
+
+func TestDeltaE(t *testing.T) {
+	// Reference pairs from Sharma, Wu and Dalal (2005).
+	tests := []struct {
+		a, b [3]float64
+		want float64
+	}{
+		{[3]float64{50, 2.6772, -79.7751}, [3]float64{50, 0, -82.7485}, 2.0425},
+		{[3]float64{50, -1.3802, -84.2814}, [3]float64{50, 0, -82.7485}, 1.0000},
+		{[3]float64{50, 2.5, 0}, [3]float64{73, 25, -18}, 27.1492},
+		{[3]float64{50, 2.5, 0}, [3]float64{61, -5, 29}, 22.8977},
+		{[3]float64{50, 0, 0}, [3]float64{50, -1, 2}, 2.3669},
+		{[3]float64{50, 2.49, -0.001}, [3]float64{50, -2.49, 0.0009}, 7.1792},
+		{[3]float64{60.2574, -34.0099, 36.2677}, [3]float64{60.4626, -34.1751, 39.4387}, 1.2644},
+		{[3]float64{2.0776, 0.0795, -1.135}, [3]float64{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for _, tt := range tests {
+		got := ciede2000(tt.a[0], tt.a[1], tt.a[2], tt.b[0], tt.b[1], tt.b[2])
+		if math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("ciede2000(%v, %v) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if got := DeltaE(MainPalette["love"], MainPalette["love"]); got != 0 {
+		t.Errorf("DeltaE() of the same colour = %v, want 0", got)
+	}
+	l, a, b := Lab(&Color{RGB: RGB{255, 255, 255}})
+	if math.Abs(l-100) > 0.001 || math.Abs(a) > 0.001 || math.Abs(b) > 0.001 {
+		t.Errorf("Lab(white) = %v, %v, %v, want 100, 0, 0", l, a, b)
+	}
+}
+
+func TestColorSpaces(t *testing.T) {
+	red := FromRGB(RGB{255, 0, 0})
+	white := FromRGB(RGB{255, 255, 255})
+
+	tests := []struct {
+		name    string
+		got     [3]float64
+		want    [3]float64
+		epsilon float64
+	}{
+		{"lab red", tuple(Lab(red)), [3]float64{54.2905, 80.8049, 69.8910}, 0.001},
+		{"lab white", tuple(Lab(white)), [3]float64{100, 0, 0}, 0.001},
+		{"lch red", tuple(LCH(red)), [3]float64{54.2905, 106.8372, 40.8577}, 0.001},
+		{"oklab red", tuple(OKLab(red)), [3]float64{0.62796, 0.22486, 0.12585}, 0.00001},
+		{"oklab white", tuple(OKLab(white)), [3]float64{1, 0, 0}, 0.00001},
+		{"oklch red", tuple(OKLCH(red)), [3]float64{0.62796, 0.25768, 29.2339}, 0.0001},
+		{"oklch white", tuple(OKLCH(white)), [3]float64{1, 0, 0}, 0.00001},
+	}
+	for _, tt := range tests {
+		for i := range tt.got {
+			if math.Abs(tt.got[i]-tt.want[i]) > tt.epsilon {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func tuple(a, b, c float64) [3]float64 {
+	return [3]float64{a, b, c}
+}
+
+// TestColorSpaceRoundTrip reads back every colour written in the Lab
+// family of formats, which must give the same RGB values at the precision
+// they are written with.
+func TestColorSpaceRoundTrip(t *testing.T) {
+	parsers := map[ColorFormat]func(a, b, c float64) *Color{
+		FormatLab:      FromLab,
+		FormatLabCSS:   FromLab,
+		FormatLCH:      FromLCH,
+		FormatLCHCSS:   FromLCH,
+		FormatOKLab:    FromOKLab,
+		FormatOKLabCSS: func(l, a, b float64) *Color { return FromOKLab(l/100, a, b) },
+		FormatOKLCH:    FromOKLCH,
+		FormatOKLCHCSS: func(l, c, h float64) *Color { return FromOKLCH(l/100, c, h) },
+	}
+
+	colors := []*Color{FromRGB(RGB{0, 0, 0}), FromRGB(RGB{255, 255, 255}), FromRGB(RGB{0, 0, 255}), FromRGB(RGB{1, 254, 2})}
+	for _, v := range Variants {
+		for _, name := range v.Colors.Names() {
+			colors = append(colors, v.Colors[name])
+		}
+	}
+
+	for format, parse := range parsers {
+		for _, c := range colors {
+			formatted := FormatColor(c, format, FormatOptions{Plain: true, Spaces: true})
+			var x, y, z float64
+			if _, err := fmt.Sscanf(strings.Replace(formatted, "%", "", 1), "%g %g %g", &x, &y, &z); err != nil {
+				t.Fatalf("%s: cannot read %q: %v", format, formatted, err)
+			}
+			if got := parse(x, y, z).RGB; got != c.RGB {
+				t.Errorf("%s: %q = %v, want %v", format, formatted, got, c.RGB)
+			}
+		}
+	}
+}
+
+func TestPaletteIndex(t *testing.T) {
+	tests := []struct {
+		rgb         RGB
+		xterm, ansi int
+	}{
+		{RGB{0, 0, 0}, 16, 0},
+		{RGB{255, 255, 255}, 231, 15},
+		{RGB{255, 0, 0}, 196, 9},
+		{RGB{0, 0, 238}, 21, 4},
+		{RGB{128, 128, 128}, 244, 8},
+		{RGB{95, 135, 175}, 67, 12},
+		{MainPalette["love"].RGB, 204, 13},
+		{MainPalette["base"].RGB, 234, 0},
+		{DawnPalette["gold"].RGB, 172, 3},
+	}
+	for _, tt := range tests {
+		c := FromRGB(tt.rgb)
+		if got := Xterm256Index(c); got != tt.xterm {
+			t.Errorf("Xterm256Index(%v) = %d, want %d", tt.rgb, got, tt.xterm)
+		}
+		if got := ANSI16Index(c); got != tt.ansi {
+			t.Errorf("ANSI16Index(%v) = %d, want %d", tt.rgb, got, tt.ansi)
+		}
+	}
+
+	if got := FormatColor(FromRGB(RGB{255, 0, 0}), FormatANSI16Escape, DefaultFormatOptions); got != "91" {
+		t.Errorf("bright red escape = %s, want 91", got)
+	}
+}
+
+func TestHexOptions(t *testing.T) {
+	alpha := 0.5
+	white := FromRGB(RGB{255, 255, 255})
+	love := MainPalette["love"]
+	love50 := *love
+	love50.Alpha = &alpha
+	quarter := 68.0 / 255
+	digits := FromRGB(RGB{0x11, 0x22, 0x33})
+	digitsAlpha := *digits
+	digitsAlpha.Alpha = &quarter
+
+	tests := []struct {
+		name   string
+		c      *Color
+		format ColorFormat
+		plain  bool
+		opts   HexOptions
+		want   string
+	}{
+		{"uppercase", love, FormatHex, false, HexOptions{Uppercase: true}, "#EB6F92"},
+		{"uppercase bgr", love, FormatHexBGR, false, HexOptions{Uppercase: true}, "0x926FEB"},
+		{"short", white, FormatHex, false, HexOptions{Short: true}, "#fff"},
+		{"short digits", digits, FormatHex, false, HexOptions{Short: true}, "#123"},
+		{"short alpha", &digitsAlpha, FormatHex, false, HexOptions{Short: true}, "#1234"},
+		{"short alpha first", &digitsAlpha, FormatHex, false, HexOptions{Short: true, AlphaFirst: true}, "#4123"},
+		{"short not possible", love, FormatHex, false, HexOptions{Short: true}, "#eb6f92"},
+		{"short only css hex", white, FormatHex0x, false, HexOptions{Short: true}, "0xffffff"},
+		{"alpha first", &love50, FormatHex, false, HexOptions{AlphaFirst: true}, "#80eb6f92"},
+		{"alpha first 0x", &love50, FormatHex0x, false, HexOptions{AlphaFirst: true}, "0x80eb6f92"},
+		{"alpha first opaque", love, FormatHex, false, HexOptions{AlphaFirst: true}, "#eb6f92"},
+		{"alpha first argb", &love50, FormatHexARGB, false, HexOptions{AlphaFirst: true}, "#80eb6f92"},
+		{"prefix", love, FormatHex, false, HexOptions{Prefix: "&H"}, "&Heb6f92"},
+		{"prefix plain", love, FormatHex0x, true, HexOptions{Prefix: "&H"}, "eb6f92"},
+		{"all", &love50, FormatHex, false, HexOptions{Uppercase: true, AlphaFirst: true, Prefix: "0x"}, "0x80EB6F92"},
+		{"decimal unaffected", love, FormatDecimal, false, HexOptions{Uppercase: true, Prefix: "0x"}, "15429522"},
+	}
+	for _, tt := range tests {
+		if got := FormatColor(tt.c, tt.format, FormatOptions{Plain: tt.plain, Commas: true, Spaces: true, Hex: tt.opts}); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		rgb    RGB
+		alpha  string
+		format ColorFormat
+		opts   FormatOptions
+	}{
+		{"#eb6f92", RGB{235, 111, 146}, "", FormatHex, FormatOptions{Commas: true, Spaces: true}},
+		{"#EB6F9280", RGB{235, 111, 146}, "0.5", FormatHex, FormatOptions{Commas: true, Spaces: true, Hex: HexOptions{Uppercase: true}}},
+		{"#fff", RGB{255, 255, 255}, "", FormatHex, FormatOptions{Commas: true, Spaces: true, Hex: HexOptions{Short: true}}},
+		{"&Heb6f92", RGB{235, 111, 146}, "", FormatHex, FormatOptions{Commas: true, Spaces: true, Hex: HexOptions{Prefix: "&H"}}},
+		{"0xeb6f92", RGB{235, 111, 146}, "", FormatHex0x, FormatOptions{Commas: true, Spaces: true}},
+		{"15429522", RGB{235, 111, 146}, "", FormatDecimal, FormatOptions{Commas: true, Spaces: true}},
+		{"eb6f92", RGB{235, 111, 146}, "", FormatHex, FormatOptions{Plain: true, Commas: true, Spaces: true}},
+		{"hsla(2, 55%, 83%, 0.5)", RGB{235, 189, 188}, "0.5", FormatHSL, FormatOptions{Commas: true, Spaces: true}},
+		{"2deg 55% 83%", RGB{235, 189, 188}, "", FormatHSLCSS, FormatOptions{Plain: true, Commas: true, Spaces: true}},
+		{"[2,0.55,0.83]", RGB{235, 189, 188}, "", FormatHSLArray, FormatOptions{Commas: true}},
+		{"rgba(235 188 186 0.5)", RGB{235, 188, 186}, "0.5", FormatRGB, FormatOptions{Spaces: true}},
+		{"rgb(235 188 186)", RGB{235, 188, 186}, "", FormatRGBCSS, FormatOptions{Commas: true, Spaces: true}},
+		{"rgb(235 188 186 / 0.5)", RGB{235, 188, 186}, "0.5", FormatRGBCSS, FormatOptions{Commas: true, Spaces: true}},
+		{"235, 188, 186", RGB{235, 188, 186}, "", FormatRGB, FormatOptions{Plain: true, Commas: true, Spaces: true}},
+		{"0.92157, 0.73725, 0.72941", RGB{235, 188, 186}, "", FormatRGBFloat, FormatOptions{Commas: true, Spaces: true, Precision: 5}},
+		{"lab(80.47, 17.18, 7.78)", RGB{235, 188, 186}, "", FormatLab, FormatOptions{Commas: true, Spaces: true}},
+		{"oklch(83.63% 0.05443 21.14)", RGB{235, 188, 186}, "", FormatOKLCHCSS, FormatOptions{Commas: true, Spaces: true}},
+		{"235;188;186", RGB{235, 188, 186}, "", FormatAnsi, FormatOptions{Commas: true, Spaces: true}},
+		{"38;5;204", RGB{255, 95, 135}, "", FormatXterm256Escape, FormatOptions{Commas: true, Spaces: true}},
+		{"rgb(235,188, 186)", RGB{235, 188, 186}, "", FormatRGB, FormatOptions{Commas: true, Spaces: true}},
+	}
+	for _, tt := range tests {
+		c, format, opts, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		alpha := ""
+		if c.Alpha != nil {
+			alpha = formatAlpha(*c.Alpha)
+		}
+		if c.RGB != tt.rgb || alpha != tt.alpha || format != tt.format || opts != tt.opts {
+			t.Errorf("%s: got %v alpha %q as %s %+v, want %v alpha %q as %s %+v", tt.in, c.RGB, alpha, format, opts, tt.rgb, tt.alpha, tt.format, tt.opts)
+		}
+	}
+
+	for _, in := range []string{"", "love", "#eb6f9", "rgb(256, 0, 0)", "hsl(2, 55, 83)", "rgb(1, 2)", "[1, 2, 3, 4, 5]", "oklab(0.5 / 1)"} {
+		if _, _, _, err := Parse(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+// TestParseRoundTrip checks that every colour written by FormatColor is
+// read back as a format and options that write it unchanged, and as the
+// same colour where the format is not ambiguous.
+func TestParseRoundTrip(t *testing.T) {
+	// Values of these formats are also values of an earlier format.
+	ambiguous := map[ColorFormat]bool{
+		FormatHexARGB: true, FormatHexBGR: true, FormatDecimalBGR: true,
+		FormatRGBArray: true, FormatRGBFloatArray: true,
+		FormatXterm256: true, FormatANSI16: true, FormatANSI16Escape: true,
+	}
+	// Palette indices read back as the xterm colour they name.
+	indexed := map[ColorFormat]bool{
+		FormatXterm256: true, FormatXterm256Escape: true, FormatANSI16: true, FormatANSI16Escape: true,
+	}
+
+	var colors []*Color
+	for _, v := range Variants {
+		for _, name := range v.Colors.Names() {
+			for _, alpha := range []float64{-1, 0, 0.25, 0.5, 1} {
+				c := *v.Colors[name]
+				if alpha >= 0 {
+					c.Alpha = &alpha
+				}
+				colors = append(colors, &c)
+			}
+		}
+	}
+
+	var options []FormatOptions
+	for _, plain := range []bool{false, true} {
+		for _, commas := range []bool{false, true} {
+			for _, precision := range []int{1, 3, 6} {
+				options = append(options,
+					FormatOptions{Plain: plain, Commas: commas, Spaces: true, Precision: precision},
+					FormatOptions{Plain: plain, Commas: true, Spaces: commas, Precision: precision, Hex: HexOptions{Uppercase: commas, Short: true, Prefix: map[bool]string{true: "&H"}[plain && !commas]}},
+				)
+			}
+		}
+	}
+
+	for _, format := range builtinFormats {
+		name := string(format)
+		for _, c := range colors {
+			for _, opts := range options {
+				s := FormatColor(c, format, opts)
+				got, gotFormat, gotOpts, err := Parse(s)
+				if err != nil {
+					t.Errorf("%s %+v: %v", format, opts, err)
+					continue
+				}
+				if again := FormatColor(got, gotFormat, gotOpts); again != s {
+					t.Errorf("%s %+v: %s read as %s %+v, which writes %s", format, opts, s, gotFormat, gotOpts, again)
+					continue
+				}
+				if gotFormat != format {
+					// Plain hex formats differ only in byte order, numbers
+					// are read as decimal, and rgb without commas is
+					// rgb-css.
+					if !ambiguous[format] && !(opts.Plain && strings.HasPrefix(name, "hex")) && !(format == FormatRGB && !opts.Commas) {
+						t.Errorf("%s %+v: %s read as %s", format, opts, s, gotFormat)
+					}
+					continue
+				}
+				// Indices and floats with fewer than 3 decimals lose
+				// the exact colour.
+				if indexed[format] || strings.HasPrefix(name, "rgb-float") && opts.Precision < 3 {
+					continue
+				}
+				wantAlpha, _ := alphaByte(c)
+				gotAlpha, _ := alphaByte(got)
+				if gotAlpha != wantAlpha {
+					t.Errorf("%s %+v: %s read as alpha %d, want %d", format, opts, s, gotAlpha, wantAlpha)
+				}
+				// HSL formats keep the rounded HSL values of the palette.
+				if strings.HasPrefix(name, "hsl") {
+					if got.HSL != c.HSL {
+						t.Errorf("%s %+v: %s read as %v, want %v", format, opts, s, got.HSL, c.HSL)
+					}
+				} else if got.RGB != c.RGB {
+					t.Errorf("%s %+v: %s read as %v, want %v", format, opts, s, got.RGB, c.RGB)
+				}
+			}
+		}
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	if err := RegisterFormat(FormatHex, FormatterFunc(func(*Color, FormatOptions) string { return "" })); err == nil {
+		t.Error("expected an error registering hex twice")
+	}
+
+	format := ColorFormat("test-channels")
+	err := RegisterFormat(format, FormatterFunc(func(c *Color, opts FormatOptions) string {
+		return fmt.Sprintf("%d/%d/%d", c.RGB.R, c.RGB.G, c.RGB.B)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterFormat(format) })
+	if got := AllFormats[len(AllFormats)-1]; got != string(format) {
+		t.Errorf("last format is %s, want %s", got, format)
+	}
+	if got := FormatColor(MainPalette["love"], format, DefaultFormatOptions); got != "235/111/146" {
+		t.Errorf("got %s, want 235/111/146", got)
+	}
+	if got := FormatColor(MainPalette["love"], "missing", DefaultFormatOptions); got != "" {
+		t.Errorf("unregistered format wrote %q", got)
+	}
+
+	UnregisterFormat(format)
+	if slices.Contains(AllFormats, string(format)) || FormatColor(MainPalette["love"], format, DefaultFormatOptions) != "" {
+		t.Errorf("%s is still registered", format)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	alpha := 0.5
+	love := MainPalette["love"]
+	love50 := *love
+	love50.Alpha = &alpha
+
+	tests := []struct {
+		pattern string
+		c       *Color
+		opts    FormatOptions
+		want    string
+	}{
+		{"Color({rf}, {gf}, {bf})", love, DefaultFormatOptions, "Color(0.922, 0.435, 0.573)"},
+		{"Color({rf}, {gf}, {bf})", love, FormatOptions{Precision: 1}, "Color(0.9, 0.4, 0.6)"},
+		{"Color({rf:2}, {gf:2}, {bf:2}, {a:2})", love, FormatOptions{Precision: 5}, "Color(0.92, 0.44, 0.57, 1.00)"},
+		{"{r={r},g={g},b={b}}", love, DefaultFormatOptions, "{r=235,g=111,b=146}"},
+		{"RGBA({r}, {g}, {b}, {a})", &love50, DefaultFormatOptions, "RGBA(235, 111, 146, 0.5)"},
+		{"hsl {h} {s} {l}", love, DefaultFormatOptions, "hsl 343 76 68"},
+		{"&H{hex}", &love50, FormatOptions{Hex: HexOptions{Uppercase: true, AlphaFirst: true, Prefix: "#"}}, "&H80EB6F92"},
+		{"{ {r} }", love, DefaultFormatOptions, "{ 235 }"},
+	}
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", tt.pattern, err)
+			continue
+		}
+		if got := p.Format(tt.c, tt.opts); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "Color()", "{red}", "{r:2}", "{a:x} {r}"} {
+		if _, err := ParsePattern(pattern); err == nil {
+			t.Errorf("%q: expected an error", pattern)
+		}
+	}
+
+	for pattern, want := range map[string]bool{"{rf}": true, "{rf:2}": false, "{r} {a}": false} {
+		p, _ := ParsePattern(pattern)
+		if got := p.UsesPrecision(); got != want {
+			t.Errorf("%s: UsesPrecision() = %v, want %v", pattern, got, want)
+		}
+	}
+}
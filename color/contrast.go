@@ -0,0 +1,84 @@
+package color
+
+import "math"
+
+// WCAG 2.x contrast ratios required for normal text.
+const (
+	ContrastAA  = 4.5
+	ContrastAAA = 7.0
+)
+
+// Luminance returns the WCAG 2.x relative luminance of c, from 0 for black
+// to 1 for white.
+func Luminance(c *Color) float64 {
+	return 0.2126*linear(c.RGB.R) + 0.7152*linear(c.RGB.G) + 0.0722*linear(c.RGB.B)
+}
+
+// linear converts an sRGB channel to linear light, from 0 to 1.
+func linear(v uint8) float64 {
+	s := float64(v) / 255
+	if s <= 0.04045 {
+		return s / 12.92
+	}
+	return math.Pow((s+0.055)/1.055, 2.4)
+}
+
+// encode converts linear light back to an sRGB channel.
+func encode(v float64) uint8 {
+	v = clamp(v, 0, 1)
+	if v <= 0.0031308 {
+		return channel(v * 12.92 * 255)
+	}
+	return channel((1.055*math.Pow(v, 1/2.4) - 0.055) * 255)
+}
+
+// Contrast returns the WCAG 2.x contrast ratio between a and b, from 1 to
+// 21. The order of the colours does not matter. A transparent a is
+// composited onto b first.
+func Contrast(a, b *Color) float64 {
+	if a.Alpha != nil {
+		a = BlendOver(a, b)
+	}
+	la, lb := Luminance(a), Luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// APCA returns the APCA lightness contrast (Lc) of text on bg, following
+// APCA-W3 0.0.98G. Dark text on a light background is positive and light
+// text on a dark background negative, with magnitudes up to about 108.
+// Transparent text is composited onto bg first.
+func APCA(text, bg *Color) float64 {
+	if text.Alpha != nil {
+		text = BlendOver(text, bg)
+	}
+
+	screen := func(c *Color) float64 {
+		y := 0.2126729*math.Pow(float64(c.RGB.R)/255, 2.4) +
+			0.7151522*math.Pow(float64(c.RGB.G)/255, 2.4) +
+			0.0721750*math.Pow(float64(c.RGB.B)/255, 2.4)
+		if y < 0.022 {
+			y += math.Pow(0.022-y, 1.414)
+		}
+		return y
+	}
+	yt, yb := screen(text), screen(bg)
+	if math.Abs(yb-yt) < 0.0005 {
+		return 0
+	}
+
+	if yb > yt {
+		s := (math.Pow(yb, 0.56) - math.Pow(yt, 0.57)) * 1.14
+		if s < 0.1 {
+			return 0
+		}
+		return (s - 0.027) * 100
+	}
+	s := (math.Pow(yb, 0.65) - math.Pow(yt, 0.62)) * 1.14
+	if s > -0.1 {
+		return 0
+	}
+	return (s + 0.027) * 100
+}
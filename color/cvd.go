@@ -0,0 +1,128 @@
+package color
+
+import "math"
+
+type Deficiency string
+
+const (
+	Protanopia    Deficiency = "protanopia"
+	Deuteranopia  Deficiency = "deuteranopia"
+	Tritanopia    Deficiency = "tritanopia"
+	Achromatopsia Deficiency = "achromatopsia"
+)
+
+var Deficiencies = []string{
+	string(Protanopia),
+	string(Deuteranopia),
+	string(Tritanopia),
+	string(Achromatopsia),
+}
+
+// cvdMatrices hold the full-severity simulations of Machado, Oliveira and
+// Fernandes (2009), applied to linear RGB.
+var cvdMatrices = map[Deficiency][3][3]float64{
+	Protanopia: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	Deuteranopia: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	Tritanopia: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+// Simulate returns c as seen with the colour vision deficiency d. Alpha and
+// the on colour are kept. An unknown deficiency returns c unchanged.
+func Simulate(c *Color, d Deficiency) *Color {
+	r, g, b := linear(c.RGB.R), linear(c.RGB.G), linear(c.RGB.B)
+
+	var rgb RGB
+	if d == Achromatopsia {
+		y := encode(0.2126*r + 0.7152*g + 0.0722*b)
+		rgb = RGB{R: y, G: y, B: y}
+	} else {
+		m, ok := cvdMatrices[d]
+		if !ok {
+			return c
+		}
+		rgb = RGB{
+			R: encode(m[0][0]*r + m[0][1]*g + m[0][2]*b),
+			G: encode(m[1][0]*r + m[1][1]*g + m[1][2]*b),
+			B: encode(m[2][0]*r + m[2][1]*g + m[2][2]*b),
+		}
+	}
+
+	simulated := FromRGB(rgb)
+	simulated.Alpha = c.Alpha
+	simulated.On = c.On
+	return simulated
+}
+
+// DeltaE returns the CIEDE2000 colour difference between a and b. A
+// difference of about 2 is just noticeable side by side, while colours
+// that must be told apart at a glance want 10 or more.
+func DeltaE(a, b *Color) float64 {
+	l1, a1, b1 := labD65(a)
+	l2, a2, b2 := labD65(b)
+	return ciede2000(l1, a1, b1, l2, a2, b2)
+}
+
+func ciede2000(l1, a1, b1, l2, a2, b2 float64) float64 {
+	rad := math.Pi / 180
+	c1, c2 := math.Hypot(a1, b1), math.Hypot(a2, b2)
+	cMean7 := math.Pow((c1+c2)/2, 7)
+	g := 0.5 * (1 - math.Sqrt(cMean7/(cMean7+math.Pow(25, 7))))
+	a1, a2 = a1*(1+g), a2*(1+g)
+	c1, c2 = math.Hypot(a1, b1), math.Hypot(a2, b2)
+
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		return math.Mod(math.Atan2(b, a)/rad+360, 360)
+	}
+	h1, h2 := hue(b1, a1), hue(b2, a2)
+
+	dl, dc := l2-l1, c2-c1
+	var dh float64
+	if c1*c2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1*c2) * math.Sin(dh/2*rad)
+
+	lMean, cMean := (l1+l2)/2, (c1+c2)/2
+	hMean := h1 + h2
+	if c1*c2 != 0 {
+		if math.Abs(h1-h2) <= 180 {
+			hMean /= 2
+		} else if hMean < 360 {
+			hMean = (hMean + 360) / 2
+		} else {
+			hMean = (hMean - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos((hMean-30)*rad) + 0.24*math.Cos(2*hMean*rad) +
+		0.32*math.Cos((3*hMean+6)*rad) - 0.20*math.Cos((4*hMean-63)*rad)
+	sl := 1 + 0.015*(lMean-50)*(lMean-50)/math.Sqrt(20+(lMean-50)*(lMean-50))
+	sc := 1 + 0.045*cMean
+	sh := 1 + 0.015*cMean*t
+	cMean7 = math.Pow(cMean, 7)
+	rt := -2 * math.Sqrt(cMean7/(cMean7+math.Pow(25, 7))) *
+		math.Sin(60*math.Exp(-math.Pow((hMean-275)/25, 2))*rad)
+
+	return math.Sqrt(math.Pow(dl/sl, 2) + math.Pow(dc/sc, 2) + math.Pow(dH/sh, 2) +
+		rt*(dc/sc)*(dH/sh))
+}
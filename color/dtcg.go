@@ -0,0 +1,278 @@
+package color
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// dtcgExtension is the $extensions key holding variant metadata that the
+// Design Tokens format has no field for.
+const dtcgExtension = "com.rosepinetheme.bloom"
+
+// dtcgTree writes every variant as a group of colour tokens in the Design
+// Tokens Community Group format. On relations are written as aliases in an
+// "on" subgroup, e.g. on.love: {rose-pine.text}.
+func dtcgTree(variants []VariantMeta) object {
+	var tree object
+	for _, v := range variants {
+		group := object{{"$type", "color"}}
+		if v.Description != "" {
+			group = append(group, field{"$description", v.Description})
+		}
+		group = append(group, field{"$extensions", object{
+			{dtcgExtension, object{{"name", v.Name}, {"appearance", v.Appearance}}},
+		}})
+
+		var on object
+		for _, name := range v.Colors.Names() {
+			c := v.Colors[name]
+			group = append(group, field{name, object{{"$value", dtcgValue(c)}}})
+			if c.On != "" {
+				on = append(on, field{name, object{{"$value", "{" + v.Id + "." + c.On + "}"}}})
+			}
+		}
+		if len(on) > 0 {
+			group = append(group, field{"on", on})
+		}
+		tree = append(tree, field{v.Id, group})
+	}
+	return tree
+}
+
+// dtcgValue writes c in the hsl colour space, so that the HSL values of the
+// palette survive a round trip, with the exact RGB value as the hex
+// fallback.
+func dtcgValue(c *Color) object {
+	opaque := *c
+	opaque.Alpha = nil
+
+	value := object{
+		{"colorSpace", "hsl"},
+		{"components", []int{int(c.HSL.H), int(c.HSL.S), int(c.HSL.L)}},
+	}
+	if c.Alpha != nil {
+		value = append(value, field{"alpha", *c.Alpha})
+	}
+	return append(value, field{"hex", FormatColor(&opaque, FormatHex, DefaultFormatOptions)})
+}
+
+// ParseDTCG reads variants from a Design Tokens file, as written by Export
+// with ExportDTCG. Each top-level group is a variant named by its key, and
+// colours may be given as hex strings, sRGB or HSL colour objects, or
+// aliases to other tokens. Variants without a name use their id, and variants without
+// an appearance are light when their base colour is.
+func ParseDTCG(data []byte) ([]VariantMeta, error) {
+	keys, groups, err := decodeOrdered(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var variants []VariantMeta
+	for _, id := range keys {
+		if strings.HasPrefix(id, "$") {
+			continue
+		}
+		v, err := parseDTCGGroup(id, groups)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		variants = append(variants, v)
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("no variants")
+	}
+	return variants, nil
+}
+
+type dtcgToken struct {
+	Value json.RawMessage `json:"$value"`
+	Type  string          `json:"$type"`
+}
+
+func parseDTCGGroup(id string, groups map[string]json.RawMessage) (VariantMeta, error) {
+	v := VariantMeta{Id: id, Name: id, Colors: Palette{}}
+
+	var group map[string]json.RawMessage
+	if err := json.Unmarshal(groups[id], &group); err != nil {
+		return v, fmt.Errorf("not a group")
+	}
+	if raw, ok := group["$type"]; ok {
+		var typ string
+		if err := json.Unmarshal(raw, &typ); err != nil || typ != "color" {
+			return v, fmt.Errorf("$type must be color")
+		}
+	}
+	if raw, ok := group["$description"]; ok {
+		if err := json.Unmarshal(raw, &v.Description); err != nil {
+			return v, fmt.Errorf("$description must be a string")
+		}
+	}
+	if raw, ok := group["$extensions"]; ok {
+		var ext map[string]struct {
+			Name       string `json:"name"`
+			Appearance string `json:"appearance"`
+		}
+		if err := json.Unmarshal(raw, &ext); err == nil {
+			if meta, ok := ext[dtcgExtension]; ok {
+				if meta.Name != "" {
+					v.Name = meta.Name
+				}
+				v.Appearance = meta.Appearance
+			}
+		}
+	}
+
+	for name, raw := range group {
+		if strings.HasPrefix(name, "$") || name == "on" {
+			continue
+		}
+		c, err := resolveDTCG(groups, raw, 0)
+		if err != nil {
+			return v, fmt.Errorf("colour %s: %w", name, err)
+		}
+		v.Colors[name] = c
+	}
+
+	if raw, ok := group["on"]; ok {
+		var on map[string]dtcgToken
+		if err := json.Unmarshal(raw, &on); err != nil {
+			return v, fmt.Errorf("on: not a group")
+		}
+		for name, token := range on {
+			c, ok := v.Colors[name]
+			if !ok {
+				return v, fmt.Errorf("on: unknown colour %s", name)
+			}
+			var alias string
+			if err := json.Unmarshal(token.Value, &alias); err != nil {
+				return v, fmt.Errorf("on.%s must be a string", name)
+			}
+			target, ok := strings.CutPrefix(strings.Trim(alias, "{}"), id+".")
+			if !strings.HasPrefix(alias, "{") || !ok {
+				return v, fmt.Errorf("on.%s must be an alias to a colour of %s", name, id)
+			}
+			c.On = target
+		}
+	}
+
+	if v.Appearance == "" {
+		v.Appearance = "dark"
+		if base, ok := v.Colors["base"]; ok && base.HSL.L > 50 {
+			v.Appearance = "light"
+		}
+	}
+	return v, nil
+}
+
+// resolveDTCG returns the colour of a token, following aliases such as
+// {rose-pine.love} through the groups of the file.
+func resolveDTCG(groups map[string]json.RawMessage, raw json.RawMessage, depth int) (*Color, error) {
+	if depth > 8 {
+		return nil, errors.New("alias cycle")
+	}
+
+	var token dtcgToken
+	if err := json.Unmarshal(raw, &token); err != nil || token.Value == nil {
+		return nil, errors.New("missing $value")
+	}
+	if token.Type != "" && token.Type != "color" {
+		return nil, fmt.Errorf("$type %q is not color", token.Type)
+	}
+
+	var s string
+	if err := json.Unmarshal(token.Value, &s); err == nil {
+		if !strings.HasPrefix(s, "{") {
+			return ParseHex(s)
+		}
+		ref, name, ok := strings.Cut(strings.Trim(s, "{}"), ".")
+		if !ok {
+			return nil, fmt.Errorf("invalid alias %s", s)
+		}
+		var group map[string]json.RawMessage
+		if err := json.Unmarshal(groups[ref], &group); err != nil || group[name] == nil {
+			return nil, fmt.Errorf("unknown alias %s", s)
+		}
+		c, err := resolveDTCG(groups, group[name], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		copied := *c
+		copied.On = ""
+		return &copied, nil
+	}
+
+	var value struct {
+		ColorSpace string    `json:"colorSpace"`
+		Components []float64 `json:"components"`
+		Alpha      *float64  `json:"alpha"`
+		Hex        string    `json:"hex"`
+	}
+	if err := json.Unmarshal(token.Value, &value); err != nil {
+		return nil, errors.New("$value must be a colour")
+	}
+
+	if len(value.Components) != 3 {
+		return nil, errors.New("colour must have 3 components")
+	}
+
+	var c *Color
+	switch value.ColorSpace {
+	case "srgb":
+		c = FromRGB(RGB{
+			R: channel(value.Components[0] * 255),
+			G: channel(value.Components[1] * 255),
+			B: channel(value.Components[2] * 255),
+		})
+	case "hsl":
+		h := math.Mod(math.Mod(value.Components[0], 360)+360, 360)
+		sat, l := value.Components[1], value.Components[2]
+		r, g, b := hslToRGB(h, clamp(sat/100, 0, 1), clamp(l/100, 0, 1))
+		c = &Color{
+			HSL: HSL{H: uint16(math.Round(h)) % 360, S: uint8(math.Round(clamp(sat, 0, 100))), L: uint8(math.Round(clamp(l, 0, 100)))},
+			RGB: RGB{R: channel(r * 255), G: channel(g * 255), B: channel(b * 255)},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported colour space %q", value.ColorSpace)
+	}
+	// The hex fallback is exact, where components may have been rounded.
+	if value.Hex != "" {
+		parsed, err := ParseHex(value.Hex)
+		if err != nil {
+			return nil, err
+		}
+		c.RGB = parsed.RGB
+	}
+	if value.Alpha != nil && *value.Alpha < 1 {
+		c.Alpha = value.Alpha
+	}
+	return c, nil
+}
+
+// decodeOrdered decodes a JSON object, returning its keys in file order.
+func decodeOrdered(data []byte) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, errors.New("expected a JSON object")
+	}
+
+	var keys []string
+	values := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = raw
+	}
+	return keys, values, nil
+}
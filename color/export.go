@@ -0,0 +1,202 @@
+package color
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type ExportFormat string
+
+const (
+	ExportJSON ExportFormat = "json"
+	ExportYAML ExportFormat = "yaml"
+	ExportTOML ExportFormat = "toml"
+	ExportCSS  ExportFormat = "css"
+	ExportSCSS ExportFormat = "scss"
+	ExportLess ExportFormat = "less"
+	ExportDTCG ExportFormat = "dtcg"
+
+	// ExportTokens is the name the Design Tokens export had before dtcg and
+	// is kept as an alias of it.
+	ExportTokens ExportFormat = "tokens"
+)
+
+var ExportFormats = []string{
+	string(ExportJSON),
+	string(ExportYAML),
+	string(ExportTOML),
+	string(ExportCSS),
+	string(ExportSCSS),
+	string(ExportLess),
+	string(ExportDTCG),
+	string(ExportTokens),
+}
+
+// Export writes variants to w in format. Variants keep their order and
+// colours follow Palette.Names, so the output is stable between runs. TOML
+// has no ordered tables, so there colours are sorted by name instead.
+func Export(w io.Writer, variants []VariantMeta, format ExportFormat) error {
+	var b bytes.Buffer
+	var err error
+	switch format {
+	case ExportJSON:
+		err = encodeJSON(&b, exportTree(variants))
+	case ExportYAML:
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err = enc.Encode(exportTree(variants)); err == nil {
+			err = enc.Close()
+		}
+	case ExportTOML:
+		enc := toml.NewEncoder(&b)
+		enc.Indent = ""
+		err = enc.Encode(exportTree(variants))
+	case ExportCSS:
+		writeVariables(&b, variants, "--", "var(--%s)", ":root {\n", "}\n", "  ")
+	case ExportSCSS:
+		writeVariables(&b, variants, "$", "$%s", "", "", "")
+	case ExportLess:
+		writeVariables(&b, variants, "@", "@%s", "", "", "")
+	case ExportDTCG, ExportTokens:
+		err = encodeJSON(&b, dtcgTree(variants))
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// exportFile is the document written by the json, yaml and toml formats.
+type exportFile struct {
+	Variants []exportVariant `json:"variants" yaml:"variants" toml:"variants"`
+}
+
+type exportVariant struct {
+	Id          string `json:"id" yaml:"id" toml:"id"`
+	Name        string `json:"name" yaml:"name" toml:"name"`
+	Appearance  string `json:"appearance" yaml:"appearance" toml:"appearance"`
+	Description string `json:"description" yaml:"description" toml:"description"`
+
+	// Colors holds an exportColor per colour in palette order. The TOML
+	// encoder cannot keep that order, so it writes ColorTable instead.
+	Colors     object                 `json:"colors" yaml:"colors" toml:"-"`
+	ColorTable map[string]exportColor `json:"-" yaml:"-" toml:"colors"`
+}
+
+type exportColor struct {
+	Hex string `json:"hex" yaml:"hex" toml:"hex"`
+	RGB string `json:"rgb" yaml:"rgb" toml:"rgb"`
+	HSL string `json:"hsl" yaml:"hsl" toml:"hsl"`
+	On  string `json:"on,omitempty" yaml:"on,omitempty" toml:"on,omitempty"`
+}
+
+// field is an entry of an ordered object.
+type field struct {
+	key   string
+	value any
+}
+
+// object is a JSON or YAML object that keeps its keys in order, where a map
+// would be sorted by key.
+type object []field
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := enc.Encode(f.key); err != nil {
+			return nil, err
+		}
+		b.WriteByte(':')
+		if err := enc.Encode(f.value); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (o object) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range o {
+		var key, value yaml.Node
+		if err := key.Encode(f.key); err != nil {
+			return nil, err
+		}
+		if err := value.Encode(f.value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &key, &value)
+	}
+	return node, nil
+}
+
+// encodeJSON writes v as indented JSON, leaving characters such as < and &
+// unescaped.
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func exportTree(variants []VariantMeta) exportFile {
+	file := exportFile{Variants: make([]exportVariant, len(variants))}
+	for i, v := range variants {
+		ev := exportVariant{
+			Id:          v.Id,
+			Name:        v.Name,
+			Appearance:  v.Appearance,
+			Description: v.Description,
+			ColorTable:  map[string]exportColor{},
+		}
+		for _, name := range v.Colors.Names() {
+			c := v.Colors[name]
+			entry := exportColor{
+				Hex: FormatColor(c, FormatHex, DefaultFormatOptions),
+				RGB: FormatColor(c, FormatRGB, DefaultFormatOptions),
+				HSL: FormatColor(c, FormatHSL, DefaultFormatOptions),
+				On:  c.On,
+			}
+			ev.Colors = append(ev.Colors, field{name, entry})
+			ev.ColorTable[name] = entry
+		}
+		file.Variants[i] = ev
+	}
+	return file
+}
+
+// writeVariables writes a variable per colour, named after the variant id
+// and colour, e.g. --rose-pine-love. On relations are written as references
+// to the colour they point to, e.g. --rose-pine-on-love: var(--rose-pine-text).
+func writeVariables(b *bytes.Buffer, variants []VariantMeta, sigil, ref, open, close, indent string) {
+	for i, v := range variants {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(b, "/* %s (%s) */\n%s", v.Name, v.Appearance, open)
+		names := v.Colors.Names()
+		for _, name := range names {
+			fmt.Fprintf(b, "%s%s%s-%s: %s;\n", indent, sigil, v.Id, name, FormatColor(v.Colors[name], FormatHex, DefaultFormatOptions))
+		}
+		for _, name := range names {
+			if on := v.Colors[name].On; on != "" {
+				fmt.Fprintf(b, "%s%s%s-on-%s: %s;\n", indent, sigil, v.Id, name, fmt.Sprintf(ref, v.Id+"-"+on))
+			}
+		}
+		b.WriteString(close)
+	}
+}
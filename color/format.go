@@ -1,6 +1,8 @@
 package color
 
 import (
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -21,57 +23,250 @@ type Color struct {
 	On    string   `json:"on,omitempty"`
 }
 
-const hex = "0123456789abcdef"
+const (
+	hex      = "0123456789abcdef"
+	hexUpper = "0123456789ABCDEF"
+)
+
+// HexOptions adjusts the hex family of formats: hex, hex-0x, hex-argb and
+// hex-bgr.
+type HexOptions struct {
+	// Uppercase writes the digits A to F in capitals.
+	Uppercase bool
+
+	// Short writes hex colours as #rgb, or #rgba, when every channel is a
+	// repeated digit, as in CSS. The other formats of the family are
+	// unaffected, as 0xfff is a different number to 0xffffff.
+	Short bool
+
+	// AlphaFirst moves alpha before the colour channels in hex and hex-0x,
+	// as in #aarrggbb.
+	AlphaFirst bool
 
-func hexComponent(c uint8) (byte, byte) {
-	return hex[c>>4], hex[c&0x0f]
+	// Prefix replaces the # or 0x written before the digits. It is
+	// dropped along with them by Plain.
+	Prefix string
+}
+
+// FormatOptions holds the options a colour is written with, alongside its
+// format.
+type FormatOptions struct {
+	// Plain drops the function name, brackets or prefix around the
+	// components, as in 235, 188, 186.
+	Plain bool
+
+	// Commas and Spaces separate the components of the list formats.
+	Commas bool
+	Spaces bool
+
+	// Precision is the number of decimals written by the float formats,
+	// such as rgb-float, or DefaultPrecision when below 1.
+	Precision int
+
+	Hex HexOptions
+}
+
+// DefaultFormatOptions separates components with commas and spaces.
+var DefaultFormatOptions = FormatOptions{Commas: true, Spaces: true}
+
+// Formatter writes colours in a format registered with RegisterFormat.
+type Formatter interface {
+	Format(c *Color, opts FormatOptions) string
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(c *Color, opts FormatOptions) string
+
+func (f FormatterFunc) Format(c *Color, opts FormatOptions) string {
+	return f(c, opts)
+}
+
+var formatters = map[ColorFormat]Formatter{}
+
+// RegisterFormat makes f available to FormatColor as format and adds it to
+// AllFormats. It must not be called while colours are being formatted.
+func RegisterFormat(format ColorFormat, f Formatter) error {
+	if _, ok := formatters[format]; ok {
+		return fmt.Errorf("format %q already exists", format)
+	}
+	formatters[format] = f
+	AllFormats = append(AllFormats, string(format))
+	return nil
+}
+
+// UnregisterFormat removes a format added with RegisterFormat, along with
+// its entry in AllFormats. It must not be called while colours are being
+// formatted.
+func UnregisterFormat(format ColorFormat) {
+	delete(formatters, format)
+	AllFormats = slices.DeleteFunc(AllFormats, func(f string) bool { return f == string(format) })
+}
+
+// builtinFormat is a format written by FormatColor itself.
+type builtinFormat ColorFormat
+
+func (f builtinFormat) Format(c *Color, opts FormatOptions) string {
+	return formatBuiltin(c, ColorFormat(f), opts)
+}
+
+func init() {
+	for _, format := range builtinFormats {
+		if err := RegisterFormat(format, builtinFormat(format)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// alphaByte returns the alpha of c from 0 to 255, and whether c has one.
+func alphaByte(c *Color) (uint8, bool) {
+	if c.Alpha == nil {
+		return 255, false
+	}
+	return uint8(*c.Alpha*255 + 0.5), true
+}
+
+// byteOrder returns the channels of c in the order written by format:
+// RGB for hex, hex-0x and decimal, then alpha if c has one; alpha first for
+// hex-argb, always written; and alpha, if any, then BGR for hex-bgr and
+// decimal-bgr, as in a Win32 COLORREF.
+func byteOrder(c *Color, format ColorFormat) []uint8 {
+	alpha, hasAlpha := alphaByte(c)
+	switch format {
+	case FormatHexARGB:
+		return []uint8{alpha, c.RGB.R, c.RGB.G, c.RGB.B}
+	case FormatHexBGR, FormatDecimalBGR:
+		if hasAlpha {
+			return []uint8{alpha, c.RGB.B, c.RGB.G, c.RGB.R}
+		}
+		return []uint8{c.RGB.B, c.RGB.G, c.RGB.R}
+	default:
+		if hasAlpha {
+			return []uint8{c.RGB.R, c.RGB.G, c.RGB.B, alpha}
+		}
+		return []uint8{c.RGB.R, c.RGB.G, c.RGB.B}
+	}
 }
 
 func formatAlpha(alpha float64) string {
 	return strconv.FormatFloat(alpha, 'f', -1, 64)
 }
 
+// formatFloat rounds v to prec decimal places, without trailing zeros.
+func formatFloat(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// spaceComponents returns the CSS function name and components of c in
+// the Lab family of formats. The CSS formats give lightness as a
+// percentage. Every format is precise enough to read back the same RGB
+// values.
+func spaceComponents(c *Color, format ColorFormat) (string, []string) {
+	switch format {
+	case FormatLab, FormatLabCSS:
+		l, a, b := Lab(c)
+		return "lab", []string{formatFloat(l, 2), formatFloat(a, 2), formatFloat(b, 2)}
+	case FormatLCH, FormatLCHCSS:
+		l, chroma, h := LCH(c)
+		return "lch", []string{formatFloat(l, 2), formatFloat(chroma, 2), formatFloat(h, 2)}
+	case FormatOKLab, FormatOKLabCSS:
+		l, a, b := OKLab(c)
+		if format == FormatOKLabCSS {
+			return "oklab", []string{formatFloat(l*100, 2), formatFloat(a, 5), formatFloat(b, 5)}
+		}
+		return "oklab", []string{formatFloat(l, 4), formatFloat(a, 5), formatFloat(b, 5)}
+	default:
+		l, chroma, h := OKLCH(c)
+		if format == FormatOKLCHCSS {
+			return "oklch", []string{formatFloat(l*100, 2), formatFloat(chroma, 5), formatFloat(h, 2)}
+		}
+		return "oklch", []string{formatFloat(l, 4), formatFloat(chroma, 5), formatFloat(h, 2)}
+	}
+}
+
 func formatUint[T ~uint8 | ~uint16](n T) string {
 	return strconv.FormatUint(uint64(n), 10)
 }
 
-func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces bool) string {
+// FormatColor writes c in format, or returns an empty string when no such
+// format is registered.
+func FormatColor(c *Color, format ColorFormat, opts FormatOptions) string {
+	f, ok := formatters[format]
+	if !ok {
+		return ""
+	}
+	return f.Format(c, opts)
+}
+
+func formatBuiltin(c *Color, format ColorFormat, opts FormatOptions) string {
+	precision := opts.Precision
+	if precision < 1 {
+		precision = DefaultPrecision
+	}
+	unit := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	}
+
 	var b strings.Builder
 
 	writeSep := func(sep byte) {
-		if sep != ',' || commas {
+		if sep != ',' || opts.Commas {
 			b.WriteByte(sep)
 		}
-		if spaces {
+		if opts.Spaces {
 			b.WriteByte(' ')
 		}
 	}
 
 	switch format {
-	case FormatHex:
+	case FormatHex, FormatHex0x, FormatHexARGB, FormatHexBGR:
 		{
-			if !plain {
-				b.WriteByte('#')
-			}
-			h, l := hexComponent(c.RGB.R)
-			b.WriteByte(h)
-			b.WriteByte(l)
-			h, l = hexComponent(c.RGB.G)
-			b.WriteByte(h)
-			b.WriteByte(l)
-			h, l = hexComponent(c.RGB.B)
-			b.WriteByte(h)
-			b.WriteByte(l)
-			if c.Alpha != nil {
-				alpha := uint8(*c.Alpha*255 + 0.5)
-				h, l = hexComponent(alpha)
-				b.WriteByte(h)
-				b.WriteByte(l)
+			channels := byteOrder(c, format)
+			if opts.Hex.AlphaFirst && c.Alpha != nil && (format == FormatHex || format == FormatHex0x) {
+				channels = append(channels[3:], channels[:3]...)
+			}
+			if !opts.Plain {
+				switch {
+				case opts.Hex.Prefix != "":
+					b.WriteString(opts.Hex.Prefix)
+				case format == FormatHex || format == FormatHexARGB:
+					b.WriteByte('#')
+				default:
+					b.WriteString("0x")
+				}
+			}
+
+			digits := hex
+			if opts.Hex.Uppercase {
+				digits = hexUpper
+			}
+			short := opts.Hex.Short && format == FormatHex && !slices.ContainsFunc(channels, func(v uint8) bool {
+				return v>>4 != v&0x0f
+			})
+			for _, v := range channels {
+				if !short {
+					b.WriteByte(digits[v>>4])
+				}
+				b.WriteByte(digits[v&0x0f])
 			}
 		}
+	case FormatDecimal, FormatDecimalBGR:
+		{
+			var n uint64
+			for _, v := range byteOrder(c, format) {
+				n = n<<8 | uint64(v)
+			}
+			b.WriteString(strconv.FormatUint(n, 10))
+		}
 	case FormatHSL:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteString("hsl")
 				if c.Alpha != nil {
 					b.WriteByte('a')
@@ -89,13 +284,13 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				writeSep(',')
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte(')')
 			}
 		}
 	case FormatHSLCSS:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteString("hsl")
 				b.WriteByte('(')
 			}
@@ -110,13 +305,13 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				b.WriteString(" / ")
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte(')')
 			}
 		}
 	case FormatHSLArray:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte('[')
 			}
 			b.WriteString(formatUint(c.HSL.H))
@@ -128,13 +323,13 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				writeSep(',')
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte(']')
 			}
 		}
 	case FormatRGB:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteString("rgb")
 				if c.Alpha != nil {
 					b.WriteByte('a')
@@ -150,13 +345,13 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				writeSep(',')
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte(')')
 			}
 		}
 	case FormatRGBCSS:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteString("rgb(")
 			}
 			b.WriteString(formatUint(c.RGB.R))
@@ -168,13 +363,13 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				b.WriteString(" / ")
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte(')')
 			}
 		}
 	case FormatRGBArray:
 		{
-			if !plain {
+			if !opts.Plain {
 				b.WriteByte('[')
 			}
 			b.WriteString(formatUint(c.RGB.R))
@@ -186,10 +381,70 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				writeSep(',')
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
-			if !plain {
+			if !opts.Plain {
+				b.WriteByte(']')
+			}
+		}
+	case FormatRGBFloat, FormatRGBFloatArray:
+		{
+			array := format == FormatRGBFloatArray && !opts.Plain
+			if array {
+				b.WriteByte('[')
+			}
+			b.WriteString(unit(float64(c.RGB.R) / 255))
+			writeSep(',')
+			b.WriteString(unit(float64(c.RGB.G) / 255))
+			writeSep(',')
+			b.WriteString(unit(float64(c.RGB.B) / 255))
+			if c.Alpha != nil {
+				writeSep(',')
+				b.WriteString(unit(*c.Alpha))
+			}
+			if array {
 				b.WriteByte(']')
 			}
 		}
+	case FormatLab, FormatLCH, FormatOKLab, FormatOKLCH:
+		{
+			name, components := spaceComponents(c, format)
+			if !opts.Plain {
+				b.WriteString(name)
+				b.WriteByte('(')
+			}
+			for i, v := range components {
+				if i > 0 {
+					writeSep(',')
+				}
+				b.WriteString(v)
+			}
+			if c.Alpha != nil {
+				writeSep(',')
+				b.WriteString(formatAlpha(*c.Alpha))
+			}
+			if !opts.Plain {
+				b.WriteByte(')')
+			}
+		}
+	case FormatLabCSS, FormatLCHCSS, FormatOKLabCSS, FormatOKLCHCSS:
+		{
+			name, components := spaceComponents(c, format)
+			if !opts.Plain {
+				b.WriteString(name)
+				b.WriteByte('(')
+			}
+			b.WriteString(components[0])
+			b.WriteString("% ")
+			b.WriteString(components[1])
+			b.WriteByte(' ')
+			b.WriteString(components[2])
+			if c.Alpha != nil {
+				b.WriteString(" / ")
+				b.WriteString(formatAlpha(*c.Alpha))
+			}
+			if !opts.Plain {
+				b.WriteByte(')')
+			}
+		}
 	case FormatAnsi:
 		{
 			b.WriteString(formatUint(c.RGB.R))
@@ -202,6 +457,20 @@ func FormatColor(c *Color, format ColorFormat, plain bool, commas bool, spaces b
 				b.WriteString(formatAlpha(*c.Alpha))
 			}
 		}
+	case FormatXterm256:
+		b.WriteString(strconv.Itoa(Xterm256Index(c)))
+	case FormatXterm256Escape:
+		b.WriteString("38;5;")
+		b.WriteString(strconv.Itoa(Xterm256Index(c)))
+	case FormatANSI16:
+		b.WriteString(strconv.Itoa(ANSI16Index(c)))
+	case FormatANSI16Escape:
+		// Bright colours have their own codes, 90 to 97.
+		if i := ANSI16Index(c); i < 8 {
+			b.WriteString(strconv.Itoa(30 + i))
+		} else {
+			b.WriteString(strconv.Itoa(90 + i - 8))
+		}
 	}
 
 	return b.String()
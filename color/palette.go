@@ -1,5 +1,12 @@
 package color
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
 type Palette map[string]*Color
 
 type VariantMeta struct {
@@ -14,6 +21,35 @@ var Accents = []string{
 	"love", "gold", "rose", "pine", "foam", "iris",
 }
 
+// ColorNames lists the palette colours in the order of the Rosé Pine style
+// guide.
+var ColorNames = []string{
+	"base", "surface", "overlay", "muted", "subtle", "text",
+	"love", "gold", "rose", "pine", "foam", "iris",
+	"highlightLow", "highlightMed", "highlightHigh",
+}
+
+// Names returns the colour names of p in a stable order: the names in
+// ColorNames first, followed by any other colours sorted alphabetically.
+func (p Palette) Names() []string {
+	names := make([]string, 0, len(p))
+	for _, name := range ColorNames {
+		if _, ok := p[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	var extra []string
+	for name := range p {
+		if !slices.Contains(ColorNames, name) {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+
+	return append(names, extra...)
+}
+
 var MainPalette = Palette{
 	"base":          {HSL: HSL{249, 22, 12}, RGB: RGB{25, 23, 36}},
 	"surface":       {HSL: HSL{247, 23, 15}, RGB: RGB{31, 29, 46}},
@@ -101,3 +137,53 @@ var Variants = []VariantMeta{
 	MoonVariantMeta,
 	DawnVariantMeta,
 }
+
+// ParseHex parses a colour written as #rrggbb or #rrggbbaa. Alpha is
+// rounded as by Parse.
+func ParseHex(s string) (*Color, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if len(digits) != 6 && len(digits) != 8 {
+		return nil, fmt.Errorf("invalid hex colour %q", s)
+	}
+	c := readHex(digits, FormatHex)
+	if c == nil {
+		return nil, fmt.Errorf("invalid hex colour %q", s)
+	}
+	return c, nil
+}
+
+// Validate reports a variant that templates cannot be rendered with: every
+// colour in ColorNames must be present, accents must name the colour used
+// on top of them, and the appearance must be dark or light.
+func (v VariantMeta) Validate() error {
+	if v.Id == "" {
+		return errors.New("missing id")
+	}
+	for _, r := range v.Id {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-') {
+			return fmt.Errorf("id %q must only contain lowercase letters, digits and hyphens", v.Id)
+		}
+	}
+	if v.Name == "" {
+		return errors.New("missing name")
+	}
+	if v.Appearance != "dark" && v.Appearance != "light" {
+		return fmt.Errorf("appearance %q must be dark or light", v.Appearance)
+	}
+
+	for _, name := range ColorNames {
+		if _, ok := v.Colors[name]; !ok {
+			return fmt.Errorf("missing colour %s", name)
+		}
+	}
+	for _, name := range v.Colors.Names() {
+		on := v.Colors[name].On
+		if on == "" && slices.Contains(Accents, name) {
+			return fmt.Errorf("accent %s is missing on", name)
+		}
+		if _, ok := v.Colors[on]; on != "" && !ok {
+			return fmt.Errorf("colour %s is on unknown colour %s", name, on)
+		}
+	}
+	return nil
+}
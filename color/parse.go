@@ -0,0 +1,470 @@
+package color
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a colour in any format written by FormatColor and reports
+// the format and options that write it back unchanged.
+//
+// Some values fit several formats, such as #eb6f9280 for hex and
+// hex-argb. The first format in AllFormats that writes the value back
+// exactly is reported, with three exceptions: numbers are read as decimal
+// rather than plain hex, 38;5;204 as an xterm-256 escape rather than an
+// ANSI triple, and rgb(235 188 186) as rgb-css rather than rgb without
+// commas. Values that no format
+// writes back exactly, such as rgb(1,2, 3), are still read with the
+// first format that accepts them.
+//
+// Palette indices are read as the xterm colour they name.
+func Parse(s string) (*Color, ColorFormat, FormatOptions, error) {
+	s = strings.TrimSpace(s)
+
+	var fallback *parsed
+	for _, p := range parseCandidates(s) {
+		if p.color == nil {
+			continue
+		}
+		if FormatColor(p.color, p.format, p.opts) == s {
+			return p.color, p.format, p.opts, nil
+		}
+		if fallback == nil {
+			fallback = &p
+		}
+	}
+	if fallback != nil {
+		return fallback.color, fallback.format, fallback.opts, nil
+	}
+	return nil, "", FormatOptions{}, fmt.Errorf("invalid colour %q", s)
+}
+
+type parsed struct {
+	color  *Color
+	format ColorFormat
+	opts   FormatOptions
+}
+
+// parseCandidates reads s with every format its syntax allows, in order of
+// preference. Formats that cannot read s give a nil colour.
+func parseCandidates(s string) []parsed {
+	var candidates []parsed
+	add := func(format ColorFormat, opts FormatOptions, c *Color) {
+		candidates = append(candidates, parsed{c, format, opts})
+	}
+	defaults := DefaultFormatOptions
+
+	var hexCandidates []parsed
+	if prefix, digits, ok := splitHex(s); ok {
+		for _, format := range hexFormats(prefix, len(digits)) {
+			opts := defaults
+			opts.Plain = prefix == ""
+			if format == FormatHex && prefix != "#" && prefix != "" {
+				opts.Hex.Prefix = prefix
+			}
+			opts.Hex.Uppercase = strings.ContainsAny(digits, "ABCDEF")
+			opts.Hex.Short = len(digits) <= 4
+			hexCandidates = append(hexCandidates, parsed{readHex(digits, format), format, opts})
+		}
+	}
+	// Plain hex without the digits a to f is far rarer than a decimal.
+	numeric := isDigits(s)
+	if !numeric {
+		candidates = append(candidates, hexCandidates...)
+	}
+
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		add(FormatDecimal, defaults, readDecimal(n, false))
+		add(FormatDecimalBGR, defaults, readDecimal(n, true))
+		if n < 256 {
+			add(FormatXterm256, defaults, xtermColor(int(n)))
+		}
+		if n < 16 {
+			add(FormatANSI16, defaults, xtermColor(int(n)))
+		}
+		if n >= 30 && n <= 37 {
+			add(FormatANSI16Escape, defaults, xtermColor(int(n)-30))
+		}
+		if n >= 90 && n <= 97 {
+			add(FormatANSI16Escape, defaults, xtermColor(int(n)-90+8))
+		}
+	}
+
+	if numeric {
+		candidates = append(candidates, hexCandidates...)
+	}
+
+	if index, ok := strings.CutPrefix(s, "38;5;"); ok {
+		if n, err := strconv.ParseUint(index, 10, 8); err == nil {
+			add(FormatXterm256Escape, defaults, xtermColor(int(n)))
+		}
+	}
+	if parts := strings.Split(s, ";"); len(parts) == 3 || len(parts) == 4 {
+		add(FormatAnsi, defaults, readRGB(parts))
+	}
+
+	wrapper, body := splitWrapper(s)
+	fields, alpha, commas, spaces, ok := splitFields(body)
+	if !ok {
+		return candidates
+	}
+	formats := listFormats
+	if !commas {
+		// rgb(235 188 186) is written by rgb-css as well as by rgb without
+		// commas; CSS syntax is what it looks like.
+		formats = slices.Clone(listFormats)
+		slices.SortStableFunc(formats, func(a, b ColorFormat) int {
+			return boolCompare(!strings.HasSuffix(string(a), "-css"), !strings.HasSuffix(string(b), "-css"))
+		})
+	}
+	for _, format := range formats {
+		plain := wrapper == ""
+		if !plain && !wrapperMatches(format, wrapper, alpha != "" || len(fields) == 4) {
+			continue
+		}
+		if plain && format == FormatRGBFloatArray {
+			continue
+		}
+		opts := FormatOptions{Plain: plain && format != FormatRGBFloat, Commas: commas, Spaces: spaces}
+		if cssFormats[format] {
+			if commas || len(fields) == 4 {
+				continue
+			}
+			opts.Commas, opts.Spaces = true, true
+		} else if alpha != "" {
+			continue
+		}
+		c, precision := readList(format, fields, alpha)
+		opts.Precision = precision
+		add(format, opts, c)
+	}
+	return candidates
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// listFormats are the formats written as a list of components, in the
+// order of AllFormats.
+var listFormats = []ColorFormat{
+	FormatHSL, FormatHSLCSS, FormatHSLArray,
+	FormatRGB, FormatRGBCSS, FormatRGBArray,
+	FormatRGBFloat, FormatRGBFloatArray,
+	FormatLab, FormatLabCSS, FormatLCH, FormatLCHCSS,
+	FormatOKLab, FormatOKLabCSS, FormatOKLCH, FormatOKLCHCSS,
+}
+
+// cssFormats separate components with spaces and alpha with a slash.
+var cssFormats = map[ColorFormat]bool{
+	FormatHSLCSS: true, FormatRGBCSS: true,
+	FormatLabCSS: true, FormatLCHCSS: true, FormatOKLabCSS: true, FormatOKLCHCSS: true,
+}
+
+// wrapperMatches reports whether format writes colours inside wrapper,
+// such as rgba( or [.
+func wrapperMatches(format ColorFormat, wrapper string, hasAlpha bool) bool {
+	switch format {
+	case FormatRGB, FormatHSL:
+		name := string(format)
+		if hasAlpha {
+			name += "a"
+		}
+		return wrapper == name+"("
+	case FormatHSLArray, FormatRGBArray, FormatRGBFloatArray:
+		return wrapper == "["
+	case FormatRGBFloat:
+		return false
+	}
+	name, _ := spaceComponents(&Color{}, format)
+	if format == FormatHSLCSS || format == FormatRGBCSS {
+		name = strings.TrimSuffix(string(format), "-css")
+	}
+	return wrapper == name+"("
+}
+
+// splitWrapper splits s into a leading function name and parenthesis, or
+// bracket, and the text inside them.
+func splitWrapper(s string) (string, string) {
+	if inner, ok := strings.CutPrefix(s, "["); ok {
+		if inner, ok := strings.CutSuffix(inner, "]"); ok {
+			return "[", inner
+		}
+		return "", s
+	}
+	if i := strings.IndexByte(s, '('); i > 0 && strings.HasSuffix(s, ")") {
+		return s[:i+1], s[i+1 : len(s)-1]
+	}
+	return "", s
+}
+
+// splitFields splits the components of a list, separated by commas or by
+// spaces, from an alpha given after a slash.
+func splitFields(body string) (fields []string, alpha string, commas, spaces, ok bool) {
+	if main, a, found := strings.Cut(body, " / "); found {
+		body, alpha = main, a
+	}
+	if strings.Contains(body, ",") {
+		fields = strings.Split(body, ",")
+		commas, spaces = true, strings.Contains(body, ", ")
+		for i, f := range fields {
+			fields[i] = strings.TrimSpace(f)
+		}
+	} else {
+		fields = strings.Fields(body)
+		spaces = true
+	}
+	if len(fields) != 3 && len(fields) != 4 || alpha != "" && len(fields) != 3 {
+		return nil, "", false, false, false
+	}
+	return fields, alpha, commas, spaces, true
+}
+
+// readList reads the components of a list format, returning the colour and
+// the precision of the float formats.
+func readList(format ColorFormat, fields []string, alpha string) (*Color, int) {
+	if len(fields) == 4 {
+		fields, alpha = fields[:3], fields[3]
+	}
+
+	var c *Color
+	precision := 0
+	switch format {
+	case FormatRGB, FormatRGBCSS, FormatRGBArray:
+		c = readRGB(fields)
+	case FormatRGBFloat, FormatRGBFloatArray:
+		_, decimals, _ := strings.Cut(fields[0], ".")
+		precision = len(decimals)
+		v, ok := readFloats(fields, "")
+		if !ok || precision == 0 || slices.ContainsFunc(v, func(x float64) bool { return x < 0 || x > 1 }) {
+			return nil, 0
+		}
+		c = FromRGB(RGB{channel(v[0] * 255), channel(v[1] * 255), channel(v[2] * 255)})
+	case FormatHSL, FormatHSLCSS, FormatHSLArray:
+		c = readHSL(format, fields)
+	default:
+		v, ok := readFloats(fields, "%")
+		if !ok || strings.HasSuffix(fields[0], "%") != cssFormats[format] {
+			return nil, 0
+		}
+		switch format {
+		case FormatLab, FormatLabCSS:
+			c = FromLab(v[0], v[1], v[2])
+		case FormatLCH, FormatLCHCSS:
+			c = FromLCH(v[0], v[1], v[2])
+		case FormatOKLab:
+			c = FromOKLab(v[0], v[1], v[2])
+		case FormatOKLabCSS:
+			c = FromOKLab(v[0]/100, v[1], v[2])
+		case FormatOKLCH:
+			c = FromOKLCH(v[0], v[1], v[2])
+		case FormatOKLCHCSS:
+			c = FromOKLCH(v[0]/100, v[1], v[2])
+		}
+	}
+	if c == nil {
+		return nil, 0
+	}
+
+	if alpha != "" {
+		a, err := strconv.ParseFloat(alpha, 64)
+		if err != nil || a < 0 || a > 1 {
+			return nil, 0
+		}
+		c.Alpha = &a
+	}
+	return c, precision
+}
+
+// readFloats reads every field as a number, after removing suffix.
+func readFloats(fields []string, suffix string) ([]float64, bool) {
+	v := make([]float64, len(fields))
+	for i, f := range fields {
+		var err error
+		if v[i], err = strconv.ParseFloat(strings.TrimSuffix(f, suffix), 64); err != nil {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// readRGB reads three channels from 0 to 255 and an optional alpha.
+func readRGB(fields []string) *Color {
+	var rgb [3]uint8
+	for i := range rgb {
+		n, err := strconv.ParseUint(fields[i], 10, 8)
+		if err != nil {
+			return nil
+		}
+		rgb[i] = uint8(n)
+	}
+	c := FromRGB(RGB{rgb[0], rgb[1], rgb[2]})
+	if len(fields) == 4 {
+		a, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil || a < 0 || a > 1 {
+			return nil
+		}
+		c.Alpha = &a
+	}
+	return c
+}
+
+// readHSL reads a hue in degrees and saturation and lightness as
+// percentages, or as fractions for hsl-array. The HSL values are kept as
+// written, as the palette defines them.
+func readHSL(format ColorFormat, fields []string) *Color {
+	hue := fields[0]
+	if format == FormatHSLCSS {
+		var ok bool
+		if hue, ok = strings.CutSuffix(hue, "deg"); !ok {
+			return nil
+		}
+	}
+	h, err := strconv.ParseUint(hue, 10, 16)
+	if err != nil || h >= 360 {
+		return nil
+	}
+
+	var sl [2]float64
+	for i, f := range fields[1:3] {
+		if format == FormatHSLArray {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil || v < 0 || v > 1 {
+				return nil
+			}
+			sl[i] = v * 100
+			continue
+		}
+		f, ok := strings.CutSuffix(f, "%")
+		n, err := strconv.ParseUint(f, 10, 8)
+		if !ok || err != nil || n > 100 {
+			return nil
+		}
+		sl[i] = float64(n)
+	}
+
+	r, g, b := hslToRGB(float64(h), sl[0]/100, sl[1]/100)
+	return &Color{
+		HSL: HSL{H: uint16(h), S: uint8(math.Round(sl[0])), L: uint8(math.Round(sl[1]))},
+		RGB: RGB{R: channel(r * 255), G: channel(g * 255), B: channel(b * 255)},
+	}
+}
+
+// splitHex splits s into a prefix and a trailing run of 3, 4, 6 or 8 hex
+// digits.
+func splitHex(s string) (string, string, bool) {
+	i := len(s)
+	for i > 0 && strings.IndexByte("0123456789abcdefABCDEF", s[i-1]) >= 0 {
+		i--
+	}
+	prefix, digits := s[:i], s[i:]
+	switch len(digits) {
+	case 3, 4, 6, 8:
+	default:
+		return "", "", false
+	}
+	if strings.ContainsAny(prefix, "()[],; ") {
+		return "", "", false
+	}
+	return prefix, digits, true
+}
+
+// hexFormats returns the hex formats that write n digits after prefix.
+func hexFormats(prefix string, n int) []ColorFormat {
+	if n <= 4 {
+		return []ColorFormat{FormatHex}
+	}
+	switch prefix {
+	case "#":
+		if n == 8 {
+			return []ColorFormat{FormatHex, FormatHexARGB}
+		}
+		return []ColorFormat{FormatHex}
+	case "0x":
+		return []ColorFormat{FormatHex0x, FormatHexBGR}
+	}
+	return []ColorFormat{FormatHex}
+}
+
+// readHex reads digits in the byte order of format, expanding shorthand.
+func readHex(digits string, format ColorFormat) *Color {
+	if len(digits) <= 4 {
+		var expanded strings.Builder
+		for _, d := range digits {
+			expanded.WriteRune(d)
+			expanded.WriteRune(d)
+		}
+		digits = expanded.String()
+	}
+	n, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return nil
+	}
+
+	channels := make([]uint8, len(digits)/2)
+	for i := range channels {
+		channels[len(channels)-1-i] = uint8(n >> (8 * i))
+	}
+	var rgb RGB
+	alpha, hasAlpha := uint8(0), len(channels) == 4
+	switch {
+	case format == FormatHexARGB:
+		alpha, rgb = channels[0], RGB{channels[1], channels[2], channels[3]}
+	case format == FormatHexBGR && hasAlpha:
+		alpha, rgb = channels[0], RGB{channels[3], channels[2], channels[1]}
+	case format == FormatHexBGR:
+		rgb = RGB{channels[2], channels[1], channels[0]}
+	default:
+		rgb = RGB{channels[0], channels[1], channels[2]}
+		if hasAlpha {
+			alpha = channels[3]
+		}
+	}
+
+	c := FromRGB(rgb)
+	// hex-argb writes opaque colours with an alpha of ff.
+	if hasAlpha && !(format == FormatHexARGB && alpha == 255) {
+		// Two decimals, unless they lose the byte.
+		a := math.Round(float64(alpha)/255*100) / 100
+		if uint8(a*255+0.5) != alpha {
+			a = math.Round(float64(alpha)/255*1000) / 1000
+		}
+		c.Alpha = &a
+	}
+	return c
+}
+
+// readDecimal reads an integer written by decimal, or decimal-bgr when
+// bgr is set. Values above 24 bits carry alpha.
+func readDecimal(n uint64, bgr bool) *Color {
+	digits := fmt.Sprintf("%06x", n)
+	if n > 0xffffff {
+		digits = fmt.Sprintf("%08x", n)
+	}
+	if bgr {
+		return readHex(digits, FormatHexBGR)
+	}
+	return readHex(digits, FormatHex)
+}
+
+// xtermColor returns the colour of an xterm palette index, with the xterm
+// defaults for the 16 ANSI colours.
+func xtermColor(i int) *Color {
+	if i < 16 {
+		return FromRGB(ansi16().values[i])
+	}
+	return FromRGB(xterm256().values[i-16])
+}
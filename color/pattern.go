@@ -0,0 +1,173 @@
+package color
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a colour format written as text with placeholders, such as
+// Color({rf}, {gf}, {bf}). Each placeholder is replaced by a component of
+// the colour:
+//
+//	{r} {g} {b}     red, green and blue from 0 to 255
+//	{rf} {gf} {bf}  red, green and blue from 0 to 1
+//	{h} {s} {l}     hue in degrees, saturation and lightness in percent
+//	{a}             alpha from 0 to 1, or 1 for opaque colours
+//	{hex}           hex digits, as written by hex with plain
+//
+// The float placeholders {rf}, {gf}, {bf} and {a} take a number of
+// decimals after a colon, as in {rf:2}. Without one, {rf}, {gf} and {bf}
+// use FormatOptions.Precision and {a} is as short as possible. Braces that
+// do not form a placeholder are written as they are, so that
+// {r={r},g={g},b={b}} writes a Lua table.
+type Pattern struct {
+	parts []patternPart
+}
+
+// patternPart is literal text, or a placeholder when name is set.
+type patternPart struct {
+	text      string
+	name      string
+	precision int
+}
+
+var placeholders = map[string]bool{
+	"r": false, "g": false, "b": false,
+	"rf": true, "gf": true, "bf": true,
+	"h": false, "s": false, "l": false,
+	"a":   true,
+	"hex": false,
+}
+
+// ParsePattern reads a pattern, rejecting unknown placeholders and
+// patterns without any.
+func ParsePattern(s string) (*Pattern, error) {
+	p := &Pattern{}
+	literal := 0
+	found := false
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' {
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		inner := s[i+1 : i+end]
+		name, digits, hasPrecision := strings.Cut(inner, ":")
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz") != "" {
+			continue
+		}
+		float, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s}", inner)
+		}
+		part := patternPart{name: name, precision: -1}
+		if hasPrecision {
+			if !float {
+				return nil, fmt.Errorf("placeholder {%s} has no decimals", name)
+			}
+			n, err := strconv.Atoi(digits)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid decimals in {%s}", inner)
+			}
+			part.precision = n
+		}
+
+		if literal < i {
+			p.parts = append(p.parts, patternPart{text: s[literal:i]})
+		}
+		p.parts = append(p.parts, part)
+		found = true
+		i += end
+		literal = i + 1
+	}
+	if !found {
+		return nil, fmt.Errorf("pattern %q has no placeholders", s)
+	}
+	if literal < len(s) {
+		p.parts = append(p.parts, patternPart{text: s[literal:]})
+	}
+	return p, nil
+}
+
+// Format writes c with every placeholder of p replaced.
+func (p *Pattern) Format(c *Color, opts FormatOptions) string {
+	var b strings.Builder
+	for _, part := range p.parts {
+		if part.name == "" {
+			b.WriteString(part.text)
+			continue
+		}
+
+		precision := part.precision
+		if precision < 0 {
+			precision = opts.Precision
+			if precision < 1 {
+				precision = DefaultPrecision
+			}
+		}
+		unit := func(v uint8) string {
+			return strconv.FormatFloat(float64(v)/255, 'f', precision, 64)
+		}
+
+		switch part.name {
+		case "r":
+			b.WriteString(formatUint(c.RGB.R))
+		case "g":
+			b.WriteString(formatUint(c.RGB.G))
+		case "b":
+			b.WriteString(formatUint(c.RGB.B))
+		case "rf":
+			b.WriteString(unit(c.RGB.R))
+		case "gf":
+			b.WriteString(unit(c.RGB.G))
+		case "bf":
+			b.WriteString(unit(c.RGB.B))
+		case "h":
+			b.WriteString(formatUint(c.HSL.H))
+		case "s":
+			b.WriteString(formatUint(c.HSL.S))
+		case "l":
+			b.WriteString(formatUint(c.HSL.L))
+		case "a":
+			alpha := 1.0
+			if c.Alpha != nil {
+				alpha = *c.Alpha
+			}
+			if part.precision < 0 {
+				b.WriteString(formatAlpha(alpha))
+			} else {
+				b.WriteString(strconv.FormatFloat(alpha, 'f', part.precision, 64))
+			}
+		case "hex":
+			hexOpts := opts
+			hexOpts.Plain = true
+			b.WriteString(formatBuiltin(c, FormatHex, hexOpts))
+		}
+	}
+	return b.String()
+}
+
+// UsesPrecision reports whether the pattern has a float placeholder whose
+// decimals are set by FormatOptions.Precision.
+func (p *Pattern) UsesPrecision() bool {
+	for _, part := range p.parts {
+		if part.precision < 0 && (part.name == "rf" || part.name == "gf" || part.name == "bf") {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesPrecision reports whether FormatOptions.Precision changes how format
+// writes colours, as it does for rgb-float.
+func UsesPrecision(format ColorFormat) bool {
+	switch format {
+	case FormatRGBFloat, FormatRGBFloatArray:
+		return true
+	}
+	f, ok := formatters[format].(interface{ UsesPrecision() bool })
+	return ok && f.UsesPrecision()
+}
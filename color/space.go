@@ -0,0 +1,159 @@
+package color
+
+import "math"
+
+// The D65 white point of sRGB, and the D50 white point that CSS Lab and LCH
+// are defined against, as chromaticities scaled to Y = 1.
+const (
+	whiteX = 0.3127 / 0.3290
+	whiteZ = (1 - 0.3127 - 0.3290) / 0.3290
+
+	d50X = 0.3457 / 0.3585
+	d50Z = (1 - 0.3457 - 0.3585) / 0.3585
+)
+
+// xyz returns the CIE XYZ coordinates of c under the D65 white point, with
+// Y from 0 to 1, using the matrix of CSS Color 4.
+func xyz(c *Color) (x, y, z float64) {
+	r, g, b := linear(c.RGB.R), linear(c.RGB.G), linear(c.RGB.B)
+	return 506752.0/1228815*r + 87881.0/245763*g + 12673.0/70218*b,
+		87098.0/409605*r + 175762.0/245763*g + 12673.0/175545*b,
+		7918.0/409605*r + 87881.0/737289*g + 1001167.0/1053270*b
+}
+
+// fromXYZ is the inverse of xyz. Colours outside the sRGB gamut are
+// clipped.
+func fromXYZ(x, y, z float64) *Color {
+	return FromRGB(RGB{
+		R: encode(12831.0/3959*x - 329.0/214*y - 1974.0/3959*z),
+		G: encode(-851781.0/878810*x + 1648619.0/878810*y + 36519.0/878810*z),
+		B: encode(705.0/12673*x - 2585.0/12673*y + 705.0/667*z),
+	})
+}
+
+// d65ToD50 adapts XYZ coordinates from the D65 white point to D50 with the
+// Bradford transform.
+func d65ToD50(x, y, z float64) (float64, float64, float64) {
+	return 1.0479297925449969*x + 0.022946870601609652*y - 0.05019226628920524*z,
+		0.02962780877005599*x + 0.9904344267538799*y - 0.017073799063418826*z,
+		-0.009243040646204504*x + 0.015055191490298152*y + 0.7518742814281371*z
+}
+
+// d50ToD65 is the inverse of d65ToD50.
+func d50ToD65(x, y, z float64) (float64, float64, float64) {
+	return 0.955473421488075*x - 0.02309845494876471*y + 0.06325924320057072*z,
+		-0.0283697093338637*x + 1.0099953980813041*y + 0.021041441191917323*z,
+		0.012314014864481998*x - 0.020507649298898964*y + 1.330365926242124*z
+}
+
+// Lab returns the CIE L*a*b* coordinates of c under the D50 white point, as
+// in CSS, with L from 0 to 100. Alpha is ignored.
+func Lab(c *Color) (l, a, b float64) {
+	x, y, z := d65ToD50(xyz(c))
+	return labFromXYZ(x, y, z, d50X, d50Z)
+}
+
+// labD65 is Lab under the D65 white point of sRGB, without adaptation.
+// Colour differences are measured with it.
+func labD65(c *Color) (l, a, b float64) {
+	x, y, z := xyz(c)
+	return labFromXYZ(x, y, z, whiteX, whiteZ)
+}
+
+func labFromXYZ(x, y, z, wx, wz float64) (l, a, b float64) {
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x/wx), f(y), f(z/wz)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// FromLab is the inverse of Lab.
+func FromLab(l, a, b float64) *Color {
+	fy := (l + 16) / 116
+	fx, fz := fy+a/500, fy-b/200
+	f := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	return fromXYZ(d50ToD65(f(fx)*d50X, f(fy), f(fz)*d50Z))
+}
+
+// LCH returns the CIE LCh coordinates of c, the polar form of Lab with the
+// hue in degrees.
+func LCH(c *Color) (l, chroma, h float64) {
+	l, a, b := Lab(c)
+	chroma, h = polar(a, b)
+	return l, chroma, h
+}
+
+// FromLCH is the inverse of LCH.
+func FromLCH(l, chroma, h float64) *Color {
+	a, b := rectangular(chroma, h)
+	return FromLab(l, a, b)
+}
+
+// OKLab returns the OKLab coordinates of c, with L from 0 to 1. Alpha is
+// ignored. The matrices of Björn Ottosson combine the conversion through
+// XYZ with the LMS cone response, so that white has no chroma.
+func OKLab(c *Color) (l, a, b float64) {
+	r, g, bl := linear(c.RGB.R), linear(c.RGB.G), linear(c.RGB.B)
+	lms := [3]float64{
+		math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*bl),
+		math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*bl),
+		math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*bl),
+	}
+	return 0.2104542553*lms[0] + 0.7936177850*lms[1] - 0.0040720468*lms[2],
+		1.9779984951*lms[0] - 2.4285922050*lms[1] + 0.4505937099*lms[2],
+		0.0259040371*lms[0] + 0.7827717662*lms[1] - 0.8086757660*lms[2]
+}
+
+// FromOKLab is the inverse of OKLab. Colours outside the sRGB gamut are
+// clipped.
+func FromOKLab(l, a, b float64) *Color {
+	cube := func(v float64) float64 { return v * v * v }
+	lms := [3]float64{
+		cube(l + 0.3963377774*a + 0.2158037573*b),
+		cube(l - 0.1055613458*a - 0.0638541728*b),
+		cube(l - 0.0894841775*a - 1.2914855480*b),
+	}
+	return FromRGB(RGB{
+		R: encode(4.0767416621*lms[0] - 3.3077115913*lms[1] + 0.2309699292*lms[2]),
+		G: encode(-1.2684380046*lms[0] + 2.6097574011*lms[1] - 0.3413193965*lms[2]),
+		B: encode(-0.0041960863*lms[0] - 0.7034186147*lms[1] + 1.7076147010*lms[2]),
+	})
+}
+
+// OKLCH returns the OKLCh coordinates of c, the polar form of OKLab with
+// the hue in degrees.
+func OKLCH(c *Color) (l, chroma, h float64) {
+	l, a, b := OKLab(c)
+	chroma, h = polar(a, b)
+	return l, chroma, h
+}
+
+// FromOKLCH is the inverse of OKLCH.
+func FromOKLCH(l, chroma, h float64) *Color {
+	a, b := rectangular(chroma, h)
+	return FromOKLab(l, a, b)
+}
+
+// polar converts the a and b axes of Lab or OKLab to chroma and a hue in
+// degrees. Greys, whose hue is meaningless, get a chroma and hue of 0.
+func polar(a, b float64) (chroma, h float64) {
+	chroma = math.Hypot(a, b)
+	if chroma < 1e-4 {
+		return 0, 0
+	}
+	return chroma, math.Mod(math.Atan2(b, a)*180/math.Pi+360, 360)
+}
+
+func rectangular(chroma, h float64) (a, b float64) {
+	rad := h * math.Pi / 180
+	return chroma * math.Cos(rad), chroma * math.Sin(rad)
+}
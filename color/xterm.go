@@ -0,0 +1,79 @@
+package color
+
+import "sync"
+
+// paletteIndex finds the nearest colour of a fixed terminal palette,
+// caching the result for each RGB value, as templates format the same
+// colours many times.
+type paletteIndex struct {
+	values []RGB
+	lab    [][3]float64
+	cache  sync.Map
+}
+
+func newPaletteIndex(values []RGB) *paletteIndex {
+	p := &paletteIndex{values: values, lab: make([][3]float64, len(values))}
+	for i, rgb := range values {
+		l, a, b := labD65(&Color{RGB: rgb})
+		p.lab[i] = [3]float64{l, a, b}
+	}
+	return p
+}
+
+// nearest returns the index of the colour nearest to c by CIEDE2000.
+func (p *paletteIndex) nearest(c *Color) int {
+	if i, ok := p.cache.Load(c.RGB); ok {
+		return i.(int)
+	}
+	l, a, b := labD65(c)
+	best, bestDelta := 0, 0.0
+	for i, v := range p.lab {
+		if delta := ciede2000(l, a, b, v[0], v[1], v[2]); i == 0 || delta < bestDelta {
+			best, bestDelta = i, delta
+		}
+	}
+	p.cache.Store(c.RGB, best)
+	return best
+}
+
+// ansi16 holds the default xterm values of the 16 ANSI colours.
+var ansi16 = sync.OnceValue(func() *paletteIndex {
+	return newPaletteIndex([]RGB{
+		{0x00, 0x00, 0x00}, {0xcd, 0x00, 0x00}, {0x00, 0xcd, 0x00}, {0xcd, 0xcd, 0x00},
+		{0x00, 0x00, 0xee}, {0xcd, 0x00, 0xcd}, {0x00, 0xcd, 0xcd}, {0xe5, 0xe5, 0xe5},
+		{0x7f, 0x7f, 0x7f}, {0xff, 0x00, 0x00}, {0x00, 0xff, 0x00}, {0xff, 0xff, 0x00},
+		{0x5c, 0x5c, 0xff}, {0xff, 0x00, 0xff}, {0x00, 0xff, 0xff}, {0xff, 0xff, 0xff},
+	})
+})
+
+// xterm256 holds indices 16 to 255 of the xterm palette: a 6×6×6 colour
+// cube followed by 24 greys. Indices 0 to 15 are left out, as terminals
+// let users change them.
+var xterm256 = sync.OnceValue(func() *paletteIndex {
+	levels := [6]uint8{0, 95, 135, 175, 215, 255}
+	var values []RGB
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				values = append(values, RGB{r, g, b})
+			}
+		}
+	}
+	for i := range 24 {
+		v := uint8(8 + 10*i)
+		values = append(values, RGB{v, v, v})
+	}
+	return newPaletteIndex(values)
+})
+
+// Xterm256Index returns the index of the xterm-256 colour perceptually
+// nearest to c by CIEDE2000, from 16 to 255. Alpha is ignored.
+func Xterm256Index(c *Color) int {
+	return 16 + xterm256().nearest(c)
+}
+
+// ANSI16Index returns the index of the ANSI colour perceptually nearest to
+// c by CIEDE2000, from 0 to 15, using the xterm defaults. Alpha is ignored.
+func ANSI16Index(c *Color) int {
+	return ansi16().nearest(c)
+}
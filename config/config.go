@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rose-pine/rose-pine-bloom/builder"
+	"github.com/rose-pine/rose-pine-bloom/color"
+	"gopkg.in/yaml.v3"
+)
+
+// Names lists the project files looked up by Find, in order of preference.
+var Names = []string{"bloom.toml", "bloom.yaml", "bloom.yml", "bloom.json"}
+
+// Config is a project file declaring the themes to build.
+type Config struct {
+	Targets []Target `json:"targets" yaml:"targets" toml:"targets"`
+
+	// Palettes declares custom variants built by every target alongside
+	// the built-in ones.
+	Palettes []Variant `json:"palettes" yaml:"palettes" toml:"palettes"`
+
+	// Formats declares custom colour formats by name as patterns, such as
+	// "Color({rf}, {gf}, {bf})". See color.Pattern for the placeholders.
+	Formats map[string]string `json:"formats" yaml:"formats" toml:"formats"`
+}
+
+// Target is a single template, or directory of templates, along with the
+// options to build it with. Unset fields take the same defaults as the
+// build command flags.
+type Target struct {
+	Template     string   `json:"template" yaml:"template" toml:"template"`
+	Output       string   `json:"output" yaml:"output" toml:"output"`
+	Prefix       string   `json:"prefix" yaml:"prefix" toml:"prefix"`
+	Format       string   `json:"format" yaml:"format" toml:"format"`
+	Plain        bool     `json:"plain" yaml:"plain" toml:"plain"`
+	Commas       *bool    `json:"commas" yaml:"commas" toml:"commas"`
+	Spaces       *bool    `json:"spaces" yaml:"spaces" toml:"spaces"`
+	Precision    *int     `json:"precision" yaml:"precision" toml:"precision"`
+	Uppercase    bool     `json:"uppercase" yaml:"uppercase" toml:"uppercase"`
+	ShortHex     bool     `json:"short-hex" yaml:"short-hex" toml:"short-hex"`
+	AlphaFirst   bool     `json:"alpha-first" yaml:"alpha-first" toml:"alpha-first"`
+	HexPrefix    string   `json:"hex-prefix" yaml:"hex-prefix" toml:"hex-prefix"`
+	Strict       bool     `json:"strict" yaml:"strict" toml:"strict"`
+	FlattenAlpha string   `json:"flatten-alpha" yaml:"flatten-alpha" toml:"flatten-alpha"`
+	Variants     []string `json:"variants" yaml:"variants" toml:"variants"`
+	Accents      []string `json:"accents" yaml:"accents" toml:"accents"`
+}
+
+// Find returns the first project file in dir, or an empty string if there
+// is none.
+func Find(dir string) (string, error) {
+	for _, name := range Names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Load reads and validates the project file at path. The file format is
+// chosen by extension, and unknown keys are rejected to catch typos.
+// Relative template and output paths are resolved against the directory of
+// the file.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := decode(path, content, &cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range cfg.Targets {
+		t := &cfg.Targets[i]
+		if !filepath.IsAbs(t.Template) {
+			t.Template = filepath.Join(dir, t.Template)
+		}
+		if t.Output == "" {
+			t.Output = "dist"
+		}
+		if !filepath.IsAbs(t.Output) {
+			t.Output = filepath.Join(dir, t.Output)
+		}
+	}
+	return &cfg, nil
+}
+
+// decode unmarshals content into v using the format given by the extension
+// of path, rejecting unknown keys.
+func decode(path string, content []byte, v any) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".toml":
+		md, err := toml.Decode(string(content), v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported config format %q", path, ext)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Targets) == 0 {
+		return errors.New("no targets")
+	}
+	if _, err := c.Variants(); err != nil {
+		return err
+	}
+	if err := c.validateFormats(); err != nil {
+		return err
+	}
+	for i, t := range c.Targets {
+		if t.Template == "" {
+			return fmt.Errorf("targets[%d]: missing template", i)
+		}
+		if _, custom := c.Formats[t.Format]; t.Format != "" && !custom && !slices.Contains(color.AllFormats, t.Format) {
+			return fmt.Errorf("targets[%d]: invalid format %q", i, t.Format)
+		}
+		if t.Precision != nil && *t.Precision < 1 {
+			return fmt.Errorf("targets[%d]: invalid precision %d", i, *t.Precision)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateFormats() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Formats)) {
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return fmt.Errorf("formats: invalid name %q, want lowercase letters, digits and dashes", name)
+		}
+		if slices.Contains(color.AllFormats, name) {
+			return fmt.Errorf("formats.%s: already a format", name)
+		}
+		if _, err := color.ParsePattern(c.Formats[name]); err != nil {
+			return fmt.Errorf("formats.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadFormats reads only the formats of the project file at path, for use
+// with a single template. Targets are neither required nor checked.
+func LoadFormats(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := decode(path, content, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateFormats(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// RegisterFormats makes the formats of c available by name, in the order
+// of their names.
+func (c *Config) RegisterFormats() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Formats)) {
+		p, err := color.ParsePattern(c.Formats[name])
+		if err != nil {
+			return fmt.Errorf("formats.%s: %w", name, err)
+		}
+		if err := color.RegisterFormat(color.ColorFormat(name), p); err != nil {
+			return fmt.Errorf("formats.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Options returns the build options for t, filling in defaults.
+func (t Target) Options() builder.Options {
+	opts := builder.Options{
+		Template:     t.Template,
+		Output:       t.Output,
+		Prefix:       t.Prefix,
+		Format:       t.Format,
+		Strict:       t.Strict,
+		FlattenAlpha: t.FlattenAlpha,
+		Variants:     t.Variants,
+		Accents:      t.Accents,
+		FormatOptions: color.FormatOptions{
+			Plain:     t.Plain,
+			Commas:    t.Commas == nil || *t.Commas,
+			Spaces:    t.Spaces == nil || *t.Spaces,
+			Precision: color.DefaultPrecision,
+			Hex: color.HexOptions{
+				Uppercase:  t.Uppercase,
+				Short:      t.ShortHex,
+				AlphaFirst: t.AlphaFirst,
+				Prefix:     t.HexPrefix,
+			},
+		},
+	}
+	if t.Precision != nil {
+		opts.Precision = *t.Precision
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "$"
+	}
+	if opts.Format == "" {
+		opts.Format = "hex"
+	}
+	return opts
+}
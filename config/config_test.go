@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"bloom.toml", `
+[[targets]]
+template = "template.json"
+output = "themes"
+commas = false
+variants = ["main", "moon"]
+
+[[targets]]
+template = "templates/shell.sh"
+format = "ansi"
+precision = 5
+uppercase = true
+`},
+		{"bloom.yaml", `
+targets:
+  - template: template.json
+    output: themes
+    commas: false
+    variants: [main, moon]
+  - template: templates/shell.sh
+    format: ansi
+    precision: 5
+    uppercase: true
+`},
+		{"bloom.json", `{
+  "targets": [
+    {"template": "template.json", "output": "themes", "commas": false, "variants": ["main", "moon"]},
+    {"template": "templates/shell.sh", "format": "ansi", "precision": 5, "uppercase": true}
+  ]
+}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.name, tt.content)
+			dir := filepath.Dir(path)
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Targets) != 2 {
+				t.Fatalf("got %d targets, want 2", len(cfg.Targets))
+			}
+
+			first := cfg.Targets[0].Options()
+			if first.Template != filepath.Join(dir, "template.json") || first.Output != filepath.Join(dir, "themes") {
+				t.Errorf("paths = %s, %s, want them relative to the config", first.Template, first.Output)
+			}
+			if first.Commas || !first.Spaces || first.Format != "hex" || first.Prefix != "$" || first.Precision != color.DefaultPrecision {
+				t.Errorf("options = %+v, want defaults with commas off", first)
+			}
+			if !slices.Equal(first.Variants, []string{"main", "moon"}) {
+				t.Errorf("variants = %v, want [main moon]", first.Variants)
+			}
+
+			second := cfg.Targets[1].Options()
+			if second.Format != "ansi" || second.Precision != 5 || !second.Hex.Uppercase || second.Output != filepath.Join(dir, "dist") {
+				t.Errorf("options = %+v, want uppercase ansi with precision 5 into dist", second)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"no targets", "bloom.toml", ``, "no targets"},
+		{"missing template", "bloom.yaml", "targets:\n  - format: hex\n", "targets[0]: missing template"},
+		{"invalid format", "bloom.json", `{"targets": [{"template": "a", "format": "hexa"}]}`, `targets[0]: invalid format "hexa"`},
+		{"invalid precision", "bloom.json", `{"targets": [{"template": "a", "precision": -1}]}`, `targets[0]: invalid precision -1`},
+		{"zero precision", "bloom.toml", "[[targets]]\ntemplate = \"a\"\nprecision = 0\n", `targets[0]: invalid precision 0`},
+		{"unknown toml key", "bloom.toml", "[[targets]]\ntemplate = \"a\"\nprefx = \"@\"\n", `unknown key "targets.prefx"`},
+		{"unknown yaml key", "bloom.yaml", "targets:\n  - template: a\n    prefx: \"@\"\n", "field prefx not found"},
+		{"unknown json key", "bloom.json", `{"targets": [{"template": "a", "prefx": "@"}]}`, `unknown field "prefx"`},
+		{"unsupported format", "bloom.ini", "", `unsupported config format ".ini"`},
+		{"builtin format name", "bloom.toml", "[formats]\nhex = \"{hex}\"\n\n[[targets]]\ntemplate = \"a\"\n", "formats.hex: already a format"},
+		{"invalid format name", "bloom.yaml", "formats:\n  \"My Format\": \"{r}\"\ntargets:\n  - template: a\n", `invalid name "My Format"`},
+		{"invalid pattern", "bloom.json", `{"formats": {"lua": "{red}"}, "targets": [{"template": "a"}]}`, "formats.lua: unknown placeholder {red}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	path := writeConfig(t, "bloom.toml", `
+[formats]
+config-lua = "{r={r},g={g},b={b}}"
+config-swift = "Color(red: {rf:2}, green: {gf:2}, blue: {bf:2})"
+
+[[targets]]
+template = "template.lua"
+format = "config-lua"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.RegisterFormats(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		color.UnregisterFormat("config-lua")
+		color.UnregisterFormat("config-swift")
+	})
+
+	love := color.MainPalette["love"]
+	if got := color.FormatColor(love, "config-lua", color.DefaultFormatOptions); got != "{r=235,g=111,b=146}" {
+		t.Errorf("config-lua = %s", got)
+	}
+	if got := color.FormatColor(love, "config-swift", color.DefaultFormatOptions); got != "Color(red: 0.92, green: 0.44, blue: 0.57)" {
+		t.Errorf("config-swift = %s", got)
+	}
+	if err := cfg.RegisterFormats(); err == nil {
+		t.Error("expected an error registering the formats twice")
+	}
+
+	// Formats alone are enough for a single template.
+	formats, err := LoadFormats(writeConfig(t, "bloom.yaml", "formats:\n  config-godot: \"Color({rf}, {gf}, {bf}, {a})\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formats.Formats["config-godot"] == "" {
+		t.Errorf("formats = %v, want config-godot", formats.Formats)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	if path, err := Find(dir); err != nil || path != "" {
+		t.Errorf("Find() = %q, %v, want nothing", path, err)
+	}
+
+	for _, name := range []string{"bloom.json", "bloom.toml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if path, err := Find(dir); err != nil || path != filepath.Join(dir, "bloom.toml") {
+		t.Errorf("Find() = %q, %v, want bloom.toml", path, err)
+	}
+}
+
+func TestLoadPalette(t *testing.T) {
+	colors := `"base": "#faf4ed", "surface": "#fffaf3", "overlay": "#f2e9e1", "muted": "#9893a5",
+      "subtle": "#797593", "text": "#575279", "love": "#b4637a", "gold": "#ea9d34",
+      "rose": "#d7827e", "pine": "#286983", "foam": "#56949f", "iris": "#907aa9",
+      "highlightLow": "#f4ede8", "highlightMed": "#dfdad9", "highlightHigh": "#cecacd"`
+	on := `"love": "surface", "gold": "surface", "rose": "surface", "pine": "surface", "foam": "surface", "iris": "surface"`
+	palette := func(colors, on string) string {
+		return `{"variants": [{"id": "acme", "name": "Acme", "appearance": "light", "colors": {` + colors + `}, "on": {` + on + `}}]}`
+	}
+
+	variants, err := LoadPalette(writeConfig(t, "palette.json", palette(colors, on)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 1 || variants[0].Id != "acme" || variants[0].Colors["love"].On != "surface" {
+		t.Errorf("LoadPalette() = %+v, want acme with love on surface", variants)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no variants", `{"variants": []}`, "no variants"},
+		{"invalid hex", palette(colors+`, "brand": "#12345"`, on), `variants[0]: colour brand: invalid hex colour "#12345"`},
+		{"unknown on", palette(colors, on+`, "brand": "text"`), "variants[0]: on: unknown colour brand"},
+		{"missing on", palette(colors, `"love": "surface"`), "variants[0]: accent gold is missing on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPalette(writeConfig(t, "palette.json", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPaletteDTCG(t *testing.T) {
+	var b bytes.Buffer
+	custom := color.DawnVariantMeta
+	custom.Id = "acme"
+	if err := color.Export(&b, []color.VariantMeta{custom}, color.ExportDTCG); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := LoadPalette(writeConfig(t, "tokens.json", b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 1 || variants[0].Id != "acme" || variants[0].Name != custom.Name || variants[0].Colors["pine"].On != "surface" {
+		t.Errorf("LoadPalette() = %+v, want acme from tokens", variants)
+	}
+
+	_, err = LoadPalette(writeConfig(t, "tokens.json", `{"acme": {"base": {"$value": "#faf4ed"}}}`))
+	if err == nil || !strings.Contains(err.Error(), "acme: missing colour surface") {
+		t.Errorf("error = %v, want missing colour", err)
+	}
+}
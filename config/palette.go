@@ -0,0 +1,138 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rose-pine/rose-pine-bloom/color"
+)
+
+// Variant is a custom variant as written in a palette file or in the
+// palettes section of a project file. Colours are hex strings, and On maps
+// a colour to the palette colour used for content on top of it.
+type Variant struct {
+	Id          string            `json:"id" yaml:"id" toml:"id"`
+	Name        string            `json:"name" yaml:"name" toml:"name"`
+	Appearance  string            `json:"appearance" yaml:"appearance" toml:"appearance"`
+	Description string            `json:"description" yaml:"description" toml:"description"`
+	Colors      map[string]string `json:"colors" yaml:"colors" toml:"colors"`
+	On          map[string]string `json:"on" yaml:"on" toml:"on"`
+}
+
+// PaletteFile is a file declaring custom variants.
+type PaletteFile struct {
+	Variants []Variant `json:"variants" yaml:"variants" toml:"variants"`
+}
+
+// LoadPalette reads the custom variants in the palette file at path. JSON
+// files holding $type or $value tokens are read as Design Tokens.
+func LoadPalette(path string) ([]color.VariantMeta, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDTCG(path, content) {
+		variants, err := color.ParseDTCG(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, v := range variants {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, v.Id, err)
+			}
+		}
+		return variants, nil
+	}
+
+	var file PaletteFile
+	if err := decode(path, content, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Variants) == 0 {
+		return nil, fmt.Errorf("%s: no variants", path)
+	}
+
+	variants, err := convertVariants("variants", file.Variants)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return variants, nil
+}
+
+// isDTCG reports whether the JSON file at path holds Design Tokens, that is
+// whether a top-level group has a $type or a token with a $value.
+func isDTCG(path string, content []byte) bool {
+	if filepath.Ext(path) != ".json" {
+		return false
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(content, &top); err != nil {
+		return false
+	}
+	for _, raw := range top {
+		var group map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &group); err != nil {
+			continue
+		}
+		if _, ok := group["$type"]; ok {
+			return true
+		}
+		for _, child := range group {
+			var token map[string]json.RawMessage
+			if err := json.Unmarshal(child, &token); err == nil && token["$value"] != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Variants returns the custom variants declared in the project file.
+func (c *Config) Variants() ([]color.VariantMeta, error) {
+	return convertVariants("palettes", c.Palettes)
+}
+
+func convertVariants(key string, variants []Variant) ([]color.VariantMeta, error) {
+	metas := make([]color.VariantMeta, len(variants))
+	for i, v := range variants {
+		meta, err := v.Meta()
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		metas[i] = meta
+	}
+	return metas, nil
+}
+
+// Meta converts v into a variant that can be built, validating it.
+func (v Variant) Meta() (color.VariantMeta, error) {
+	meta := color.VariantMeta{
+		Id:          v.Id,
+		Name:        v.Name,
+		Appearance:  v.Appearance,
+		Description: v.Description,
+		Colors:      color.Palette{},
+	}
+	for name, hex := range v.Colors {
+		c, err := color.ParseHex(hex)
+		if err != nil {
+			return meta, fmt.Errorf("colour %s: %w", name, err)
+		}
+		meta.Colors[name] = c
+	}
+	for name, on := range v.On {
+		c, ok := meta.Colors[name]
+		if !ok {
+			return meta, fmt.Errorf("on: unknown colour %s", name)
+		}
+		c.On = on
+	}
+
+	if err := meta.Validate(); err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
@@ -31,10 +31,11 @@
               ./builder
               ./cmd
               ./color
+              ./config
             ];
           };
 
-          vendorHash = "sha256-LSy43oMhDnAySHXnF1ZsBgm7Whlq1rw5XQaUcBDBhg4=";
+          vendorHash = "sha256-vIDjRgf40jJMCaMCv09WFPGcOt+1eWyI5yO+6DaIaRg=";
 
           meta.mainProgram = "rose-pine-bloom";
         };
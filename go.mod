@@ -2,9 +2,11 @@ module github.com/rose-pine/rose-pine-bloom
 
 go 1.26.4
 
-require github.com/spf13/cobra v1.9.1
-
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect